@@ -2,9 +2,12 @@ package backup
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+
+	"goback/utils"
 )
 
 // ExecuteCommand выполняет команду и проверяет наличие output_file
@@ -16,8 +19,8 @@ func ExecuteCommand(command string, outputFile string) error {
 
 	// Выполняем команду через shell для поддержки многострочных команд и пайпов
 	cmd := exec.Command("sh", "-c", command)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = utils.MaskWriter(os.Stdout)
+	cmd.Stderr = utils.MaskWriter(os.Stderr)
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("command failed: %w", err)
@@ -31,3 +34,70 @@ func ExecuteCommand(command string, outputFile string) error {
 	return nil
 }
 
+// RunRestoreCommand выполняет restore_command через shell, передавая в stdin данные, записанные write
+func RunRestoreCommand(command string, write func(w io.Writer) error) error {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return fmt.Errorf("empty restore command")
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = utils.MaskWriter(os.Stdout)
+	cmd.Stderr = utils.MaskWriter(os.Stderr)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start restore command: %w", err)
+	}
+
+	writeErr := write(stdin)
+	stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("restore command failed: %w", err)
+	}
+
+	if writeErr != nil {
+		return fmt.Errorf("failed to stream dump to restore command: %w", writeErr)
+	}
+
+	return nil
+}
+
+// RunCaptureCommand выполняет команду через shell и передает ее stdout в read.
+// Если read завершился с ошибкой, команда принудительно останавливается
+func RunCaptureCommand(command string, read func(r io.Reader) error) error {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return fmt.Errorf("empty command")
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = utils.MaskWriter(os.Stderr)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+
+	if err := read(stdout); err != nil {
+		// Команда может быть заблокирована на записи в pipe, который больше никто не читает
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+
+	return nil
+}
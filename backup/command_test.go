@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goback/config"
+)
+
+func TestRunCaptureCommand(t *testing.T) {
+	var captured string
+	err := RunCaptureCommand("echo dump; echo log >&2", func(r io.Reader) error {
+		data, err := io.ReadAll(r)
+		captured = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if captured != "dump\n" {
+		t.Errorf("captured %q, want only stdout", captured)
+	}
+
+	// Ненулевой код выхода - ошибка, даже если stdout прочитан полностью
+	err = RunCaptureCommand("echo partial; exit 3", func(r io.Reader) error {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	})
+	if err == nil {
+		t.Error("non-zero exit code was not reported")
+	}
+
+	// Ошибка чтения останавливает команду, которая иначе не завершится
+	readErr := errors.New("disk full")
+	err = RunCaptureCommand("yes", func(r io.Reader) error {
+		if _, err := io.ReadFull(r, make([]byte, 1024)); err != nil {
+			return err
+		}
+		return readErr
+	})
+	if !errors.Is(err, readErr) {
+		t.Errorf("RunCaptureCommand error = %v, want read error", err)
+	}
+
+	if err := RunCaptureCommand("  ", func(io.Reader) error { return nil }); err == nil {
+		t.Error("empty command was accepted")
+	}
+}
+
+func TestRunRestoreCommand(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "restored.sql")
+	err := RunRestoreCommand("cat > '"+output+"'", func(w io.Writer) error {
+		_, err := io.WriteString(w, "CREATE TABLE t;\n")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(output); err != nil || string(data) != "CREATE TABLE t;\n" {
+		t.Errorf("restore command received %q, %v", data, err)
+	}
+
+	write := func(w io.Writer) error {
+		_, err := io.WriteString(w, "dump")
+		return err
+	}
+	tests := []struct {
+		name    string
+		command string
+		write   func(w io.Writer) error
+		wantErr string
+	}{
+		{name: "non-zero exit", command: "cat >/dev/null; exit 2", write: write, wantErr: "restore command failed"},
+		{name: "write error", command: "cat >/dev/null", write: func(io.Writer) error { return errors.New("corrupted archive") }, wantErr: "corrupted archive"},
+		// Команда, не прочитавшая дамп, не считается успешным восстановлением
+		{
+			name:    "command exits early",
+			command: "exit 0",
+			write: func(w io.Writer) error {
+				_, err := w.Write(make([]byte, 1<<20))
+				return err
+			},
+			wantErr: "failed to stream dump",
+		},
+		{name: "empty command", command: " \n", write: write, wantErr: "empty restore command"},
+	}
+
+	for _, tt := range tests {
+		err := RunRestoreCommand(tt.command, tt.write)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// newCaptureBackup возвращает исполнитель и бэкап, сжимающий stdout команды command в gzip
+func newCaptureBackup(t *testing.T, command string) (*Executor, *config.BackupConfig) {
+	t.Helper()
+
+	global := &config.GlobalConfig{
+		BackupDir:    t.TempDir(),
+		FilenameMask: "%name%-%Y%m%d%H%M%S",
+		Retention:    config.RetentionPolicy{Daily: 7},
+	}
+	backupCfg := &config.BackupConfig{
+		Name:         "db",
+		Subdirectory: "db",
+		Command:      command,
+		Capture:      config.CaptureStdout,
+		Compression:  config.CompressionConfig{Type: "gzip"},
+	}
+	return NewExecutor(global), backupCfg
+}
+
+func TestCaptureStdoutBackup(t *testing.T) {
+	executor, backupCfg := newCaptureBackup(t, "echo 'CREATE TABLE t;'")
+	if err := executor.ExecuteBackup(backupCfg); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := executor.globalConfig.Storage().Open(latestArchive(t, executor, backupCfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil || string(data) != "CREATE TABLE t;\n" {
+		t.Errorf("archive content = %q, %v", data, err)
+	}
+}
+
+func TestCaptureStdoutBackupFailed(t *testing.T) {
+	// Команда успевает вывести часть дампа перед ошибкой
+	executor, backupCfg := newCaptureBackup(t, "echo 'CREATE TABLE t;'; exit 1")
+	if err := executor.ExecuteBackup(backupCfg); err == nil {
+		t.Fatal("backup of failed command succeeded")
+	}
+
+	entries, err := os.ReadDir(filepath.Join(executor.globalConfig.BackupDir, backupCfg.Subdirectory))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("partial archive left after failed command: %v", entries)
+	}
+}
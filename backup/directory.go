@@ -1,15 +1,23 @@
 package backup
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"goback/compression"
+	"goback/manifest"
 )
 
-// CopyDirectory копирует директорию с поддержкой exclude_patterns
-func CopyDirectory(source, destination string, excludePatterns []string) error {
+// CopyDirectory копирует директорию с поддержкой exclude_patterns, сохраняя права, владельцев,
+// время модификации, расширенные атрибуты и жесткие ссылки, чтобы архив копии не отличался от архива оригинала.
+// Недоступные файлы и директории пропускаются с предупреждением. Если передан манифест, в него записываются
+// скопированные файлы и директории, исключенные, специальные и недоступные пути
+func CopyDirectory(source, destination string, excludePatterns []string, m *manifest.Manifest) error {
 	// Создаем целевую директорию
 	if err := os.MkdirAll(destination, 0755); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
@@ -26,17 +34,38 @@ func CopyDirectory(source, destination string, excludePatterns []string) error {
 		return fmt.Errorf("failed to get absolute path for destination: %w", err)
 	}
 
-	return filepath.Walk(absSource, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			// Пропускаем файлы/директории, к которым нет доступа
-			return nil
-		}
+	// Атрибуты директорий переносятся после копирования их содержимого
+	type copiedDir struct {
+		source      string
+		destination string
+		info        os.FileInfo
+	}
+	var dirs []copiedDir
+
+	// Первая копия каждого файла с несколькими жесткими ссылками и ее запись в манифесте
+	type copiedLink struct {
+		destination string
+		file        manifest.File
+	}
+	links := make(map[compression.FileID]copiedLink)
 
+	err = filepath.Walk(absSource, func(path string, info os.FileInfo, walkErr error) error {
 		relPath, err := filepath.Rel(absSource, path)
 		if err != nil {
 			return err
 		}
 
+		if walkErr != nil {
+			// Копия без содержимого source не должна выглядеть успешным бэкапом
+			if relPath == "." {
+				return fmt.Errorf("failed to read source: %w", walkErr)
+			}
+			if info == nil || !info.IsDir() {
+				compression.SkipUnreadable(m, relPath, false, walkErr)
+				return nil
+			}
+		}
+
 		// Пропускаем корневую директорию (relPath == ".")
 		if relPath == "." {
 			return nil
@@ -45,21 +74,44 @@ func CopyDirectory(source, destination string, excludePatterns []string) error {
 		// Пропускаем специальные файлы (socket, named pipe, device files)
 		mode := info.Mode()
 		if mode&os.ModeSocket != 0 || mode&os.ModeNamedPipe != 0 || mode&os.ModeDevice != 0 {
+			if m != nil {
+				m.AddSpecial(filepath.ToSlash(relPath))
+			}
 			return nil
 		}
 
 		// Проверяем exclude patterns
 		if shouldExclude(relPath, excludePatterns) {
 			if info.IsDir() {
+				if m != nil {
+					m.AddExcluded(filepath.ToSlash(relPath) + "/")
+				}
 				return filepath.SkipDir
 			}
+			if m != nil {
+				m.AddExcluded(filepath.ToSlash(relPath))
+			}
 			return nil
 		}
 
 		destPath := filepath.Join(absDestination, relPath)
 
 		if info.IsDir() {
-			return os.MkdirAll(destPath, info.Mode())
+			if walkErr != nil {
+				// Директория, которую не удалось прочитать, копируется без содержимого
+				compression.SkipUnreadable(m, relPath, true, walkErr)
+			}
+
+			dirs = append(dirs, copiedDir{source: path, destination: destPath, info: info})
+			if m != nil {
+				m.AddFile(manifest.File{
+					Path:    filepath.ToSlash(relPath),
+					Type:    manifest.TypeDirectory,
+					Mode:    manifest.FormatMode(info.Mode()),
+					ModTime: info.ModTime(),
+				})
+			}
+			return os.MkdirAll(destPath, 0700)
 		}
 
 		// Проверяем, является ли это симлинком
@@ -68,7 +120,7 @@ func CopyDirectory(source, destination string, excludePatterns []string) error {
 			// Копируем симлинк как симлинк
 			target, err := os.Readlink(path)
 			if err != nil {
-				// Не удалось прочитать симлинк, пропускаем
+				compression.SkipUnreadable(m, relPath, false, err)
 				return nil
 			}
 			// Проверяем, существует ли уже файл/симлинк по целевому пути
@@ -79,18 +131,80 @@ func CopyDirectory(source, destination string, excludePatterns []string) error {
 				}
 			}
 			// Создаем новый симлинк
-			return os.Symlink(target, destPath)
+			if err := os.Symlink(target, destPath); err != nil {
+				return err
+			}
+			if err := compression.CopyAttributes(path, destPath, info); err != nil {
+				return err
+			}
+			if m != nil {
+				m.AddFile(manifest.File{
+					Path:    filepath.ToSlash(relPath),
+					Type:    manifest.TypeSymlink,
+					Mode:    manifest.FormatMode(info.Mode()),
+					ModTime: info.ModTime(),
+					Link:    target,
+				})
+			}
+			return nil
+		}
+
+		// Повторная жесткая ссылка создается ссылкой на уже скопированный файл
+		id, hardlink := compression.HardlinkID(info)
+		if first, seen := links[id]; hardlink && seen {
+			if err := os.Link(first.destination, destPath); err != nil {
+				return err
+			}
+			if m != nil {
+				file := first.file
+				file.Path = filepath.ToSlash(relPath)
+				m.AddFile(file)
+			}
+			return nil
 		}
 
-		// Обычный файл - проверяем, что он все еще существует перед копированием
-		// (может быть удален между моментом обнаружения и копированием)
-		if _, err := os.Lstat(path); os.IsNotExist(err) {
-			// Файл не существует, пропускаем
+		// Файл мог быть удален после обхода или быть недоступным для чтения
+		srcFile, err := os.Open(path)
+		if err != nil {
+			compression.SkipUnreadable(m, relPath, false, err)
 			return nil
 		}
+		sum, err := copyFile(srcFile, destPath, info.Mode())
+		srcFile.Close()
+		if err != nil {
+			return err
+		}
 
-		return copyFile(path, destPath, info.Mode())
+		file := manifest.File{
+			Path:    filepath.ToSlash(relPath),
+			Type:    manifest.TypeFile,
+			Size:    info.Size(),
+			Mode:    manifest.FormatMode(info.Mode()),
+			ModTime: info.ModTime(),
+			SHA256:  sum,
+		}
+		if m != nil {
+			m.AddFile(file)
+		}
+		if hardlink {
+			links[id] = copiedLink{destination: destPath, file: file}
+		}
+
+		// Сохраняем владельца, права и время модификации, чтобы они попали в архив
+		return compression.CopyAttributes(path, destPath, info)
 	})
+	if err != nil {
+		return err
+	}
+
+	// Идем в обратном порядке, чтобы вложенные директории обрабатывались раньше родительских
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := compression.CopyAttributes(dirs[i].source, dirs[i].destination, dirs[i].info); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func shouldExclude(path string, patterns []string) bool {
@@ -131,20 +245,19 @@ func shouldExclude(path string, patterns []string) bool {
 	return false
 }
 
-func copyFile(src, dst string, mode os.FileMode) error {
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer srcFile.Close()
-
+// copyFile копирует содержимое открытого файла srcFile в dst и возвращает его SHA-256
+func copyFile(srcFile *os.File, dst string, mode os.FileMode) (string, error) {
 	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dstFile, hash), srcFile); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
@@ -0,0 +1,35 @@
+//go:build linux
+
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopyDirectoryPreservesXattrs(t *testing.T) {
+	source := t.TempDir()
+	path := filepath.Join(source, "file")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Setxattr(path, "user.goback", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+
+	destination := filepath.Join(t.TempDir(), "copy")
+	if err := CopyDirectory(source, destination, nil, nil); err != nil {
+		t.Fatalf("CopyDirectory: %v", err)
+	}
+
+	value := make([]byte, 64)
+	n, err := syscall.Getxattr(filepath.Join(destination, "file"), "user.goback", value)
+	if err != nil {
+		t.Fatalf("extended attribute not copied: %v", err)
+	}
+	if string(value[:n]) != "value" {
+		t.Errorf("user.goback = %q, want %q", value[:n], "value")
+	}
+}
@@ -0,0 +1,120 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goback/manifest"
+)
+
+func TestCopyDirectoryPreservesMetadata(t *testing.T) {
+	source := t.TempDir()
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := os.Mkdir(filepath.Join(source, "private"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "private", "data"), []byte("content"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(source, "private", "data"), filepath.Join(source, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "skip.log"), []byte("log"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(source, "private"), 0550); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(filepath.Join(source, "private"), 0700) })
+	if err := os.Chtimes(filepath.Join(source, "private"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(t.TempDir(), "copy")
+	t.Cleanup(func() { os.Chmod(filepath.Join(destination, "private"), 0700) })
+
+	m := manifest.New("test", source)
+	if err := CopyDirectory(source, destination, []string{"*.log"}, m); err != nil {
+		t.Fatalf("CopyDirectory: %v", err)
+	}
+
+	dir, err := os.Stat(filepath.Join(destination, "private"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Mode().Perm() != 0550 || !dir.ModTime().Equal(modTime) {
+		t.Errorf("private: mode %v, mtime %v; want 0550, %v", dir.Mode().Perm(), dir.ModTime(), modTime)
+	}
+
+	data, err := os.Stat(filepath.Join(destination, "private", "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := os.Stat(filepath.Join(destination, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(data, link) {
+		t.Error("hard link copied as a separate file")
+	}
+	if data.Mode().Perm() != 0640 {
+		t.Errorf("data mode %v, want 0640", data.Mode().Perm())
+	}
+
+	if _, err := os.Stat(filepath.Join(destination, "skip.log")); !os.IsNotExist(err) {
+		t.Errorf("excluded file copied: %v", err)
+	}
+	// Директория private записывается в манифест вместе с файлами
+	if len(m.Files) != 3 || len(m.Excluded) != 1 || len(m.Skipped) != 0 {
+		t.Errorf("manifest: %d entries, %d excluded, %d skipped; want 3, 1 and 0", len(m.Files), len(m.Excluded), len(m.Skipped))
+	}
+	for _, file := range m.Files {
+		if file.Path == "private" && (file.Type != manifest.TypeDirectory || file.Mode != "0550") {
+			t.Errorf("private in manifest: type %s, mode %s", file.Type, file.Mode)
+		}
+	}
+}
+
+func TestCopyDirectoryUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read files without read permission")
+	}
+
+	source := t.TempDir()
+	if err := os.Mkdir(filepath.Join(source, "private"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "secret.key", filepath.Join("private", "data")} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"secret.key", "private"} {
+		if err := os.Chmod(filepath.Join(source, name), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { os.Chmod(filepath.Join(source, "private"), 0755) })
+
+	destination := filepath.Join(t.TempDir(), "copy")
+	t.Cleanup(func() { os.Chmod(filepath.Join(destination, "private"), 0755) })
+
+	m := manifest.New("test", source)
+	if err := CopyDirectory(source, destination, nil, m); err != nil {
+		t.Fatalf("CopyDirectory: %v", err)
+	}
+
+	// Недоступные файл и содержимое директории пропускаются, директория копируется пустой
+	if _, err := os.Stat(filepath.Join(destination, "secret.key")); !os.IsNotExist(err) {
+		t.Errorf("unreadable file copied: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(destination, "private")); err != nil {
+		t.Errorf("unreadable directory not copied: %v", err)
+	}
+	if len(m.Files) != 2 || len(m.Skipped) != 2 || !m.IsSkipped("secret.key") || !m.IsSkipped("private/data") {
+		t.Errorf("manifest: %d entries, skipped %v; want 2 and private/, secret.key", len(m.Files), m.Skipped)
+	}
+}
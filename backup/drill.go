@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/compression"
+	"goback/config"
+	"goback/retention"
+	"goback/storage"
+	"goback/utils"
+)
+
+// DrillResult - результат проверки восстановления бэкапа
+type DrillResult struct {
+	Backup   string
+	Archive  string
+	Files    int
+	Duration time.Duration
+	Err      error
+}
+
+// RunDrill распаковывает последний архив бэкапа во временную директорию,
+// проверяет количество файлов и выполняет команду проверки из секции drill.
+// Результат сохраняется в отчете исполнителя
+func (e *Executor) RunDrill(backupConfig *config.BackupConfig) DrillResult {
+	start := time.Now()
+	result := e.runDrill(backupConfig)
+	result.Backup = backupConfig.Name
+	result.Duration = time.Since(start)
+
+	e.drills = append(e.drills, result)
+	return result
+}
+
+// DrillResults возвращает результаты всех выполненных проверок восстановления
+func (e *Executor) DrillResults() []DrillResult {
+	return e.drills
+}
+
+func (e *Executor) runDrill(backupConfig *config.BackupConfig) DrillResult {
+	var result DrillResult
+
+	drill := backupConfig.Drill
+	if drill == nil {
+		drill = &config.DrillConfig{}
+	}
+
+	backend := e.globalConfig.Storage()
+	files, err := retention.ListBackups(backend, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.Location())
+	if err != nil {
+		result.Err = fmt.Errorf("failed to list archives: %w", err)
+		return result
+	}
+	if len(files) == 0 {
+		result.Err = fmt.Errorf("no archives found")
+		return result
+	}
+
+	archivePath := files[len(files)-1].Path
+	result.Archive = filepath.Base(archivePath)
+
+	// Как и restore, проверка не распаковывает неподписанный или подмененный архив
+	if signing := e.globalConfig.Signing; signing.Enabled() {
+		if err := signing.Verify(backend, archivePath); err != nil {
+			result.Err = fmt.Errorf("signature check failed: %w", err)
+			return result
+		}
+	}
+
+	decompressor, err := compression.NewDecompressorFor(result.Archive, e.globalConfig.ReadOptionsFor(backupConfig))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	// Распаковываем во временную директорию, которая удаляется после проверки
+	tmpDir, err := os.MkdirTemp("", "drill-*")
+	if err != nil {
+		result.Err = fmt.Errorf("failed to create temp directory: %w", err)
+		return result
+	}
+	defer os.RemoveAll(tmpDir)
+
+	utils.Printf("Extracting %s for restore drill...\n", result.Archive)
+	if err := decompressor.Decompress(archivePath, tmpDir); err != nil {
+		result.Err = fmt.Errorf("failed to extract: %w", err)
+		return result
+	}
+
+	result.Files, err = countFiles(tmpDir)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to count files: %w", err)
+		return result
+	}
+
+	if result.Files < drill.MinFiles {
+		result.Err = fmt.Errorf("restored %d files, expected at least %d", result.Files, drill.MinFiles)
+		return result
+	}
+
+	if command := strings.TrimSpace(drill.Command); command != "" {
+		utils.Printf("Running drill check: %s\n", command)
+
+		// Команда выполняется в директории с восстановленными файлами
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = tmpDir
+		cmd.Env = append(os.Environ(),
+			"GOBACK_DRILL_DIR="+tmpDir,
+			"GOBACK_DRILL_ARCHIVE="+storage.Location(backend, archivePath),
+		)
+		cmd.Stdout = utils.MaskWriter(os.Stdout)
+		cmd.Stderr = utils.MaskWriter(os.Stderr)
+
+		if err := cmd.Run(); err != nil {
+			result.Err = fmt.Errorf("check command failed: %w", err)
+			return result
+		}
+	}
+
+	return result
+}
+
+// countFiles считает обычные файлы в директории рекурсивно
+func countFiles(dir string) (int, error) {
+	count := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,177 @@
+package backup
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goback/config"
+	"goback/retention"
+	"goback/signature"
+)
+
+// newTestBackup создает бэкап директории с файлами files и возвращает исполнитель и его конфигурацию
+func newTestBackup(t *testing.T, files map[string]string) (*Executor, *config.BackupConfig) {
+	t.Helper()
+
+	source := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	global := &config.GlobalConfig{
+		BackupDir:    t.TempDir(),
+		FilenameMask: "%name%-%Y%m%d%H%M%S",
+		Retention:    config.RetentionPolicy{Daily: 7},
+	}
+	backupCfg := &config.BackupConfig{
+		Name:         "site",
+		Subdirectory: "site",
+		SourceDir:    source,
+		Compression:  config.CompressionConfig{Type: "tar.gz"},
+		Drill:        &config.DrillConfig{},
+	}
+
+	executor := NewExecutor(global)
+	if err := executor.ExecuteBackup(backupCfg); err != nil {
+		t.Fatal(err)
+	}
+	return executor, backupCfg
+}
+
+// latestArchive возвращает имя последнего архива бэкапа в хранилище
+func latestArchive(t *testing.T, e *Executor, backupCfg *config.BackupConfig) string {
+	t.Helper()
+
+	files, err := retention.ListBackups(e.globalConfig.Storage(), backupCfg.Subdirectory, backupCfg.Name, e.globalConfig.Location())
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no archives: %v", err)
+	}
+	return files[len(files)-1].Path
+}
+
+func TestDrillSignature(t *testing.T) {
+	executor, backupCfg := newTestBackup(t, map[string]string{"index.html": "<html>"})
+
+	key, err := signature.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor.globalConfig.Signing.PublicKeys = []string{signature.FormatPublicKey(key.Public().(ed25519.PublicKey))}
+
+	// Архив создан без key_file и не подписан
+	result := executor.RunDrill(backupCfg)
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "signature check failed") {
+		t.Fatalf("drill of unsigned archive: %v, want signature error", result.Err)
+	}
+	if result.Files != 0 {
+		t.Errorf("unsigned archive was extracted: %d files", result.Files)
+	}
+
+	backend := executor.globalConfig.Storage()
+	if err := signature.Sign(backend, latestArchive(t, executor, backupCfg), key); err != nil {
+		t.Fatal(err)
+	}
+	if result := executor.RunDrill(backupCfg); result.Err != nil || result.Files != 1 {
+		t.Errorf("drill of signed archive: %d files, %v", result.Files, result.Err)
+	}
+
+	// Подпись другим ключом не принимается
+	other, err := signature.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signature.Sign(backend, latestArchive(t, executor, backupCfg), other); err != nil {
+		t.Fatal(err)
+	}
+	if result := executor.RunDrill(backupCfg); result.Err == nil {
+		t.Error("archive signed by an untrusted key passed the drill")
+	}
+}
+
+func TestRunDrill(t *testing.T) {
+	executor, backupCfg := newTestBackup(t, map[string]string{
+		"index.html":   "<html>",
+		"css/site.css": "body {}",
+	})
+	dirFile := filepath.Join(t.TempDir(), "drill-dir")
+
+	tests := []struct {
+		name    string
+		drill   config.DrillConfig
+		wantErr string
+	}{
+		{name: "no checks"},
+		{name: "enough files", drill: config.DrillConfig{MinFiles: 2}},
+		{name: "too few files", drill: config.DrillConfig{MinFiles: 3}, wantErr: "restored 2 files, expected at least 3"},
+		{
+			name:  "check command passes",
+			drill: config.DrillConfig{Command: `grep -q html index.html && test -f "$GOBACK_DRILL_DIR/css/site.css" && echo "$GOBACK_DRILL_DIR" > ` + dirFile},
+		},
+		{name: "check command fails", drill: config.DrillConfig{Command: "test -f missing.txt"}, wantErr: "check command failed"},
+		// Команда не выполняется, если файлов меньше ожидаемого
+		{name: "command after failed count", drill: config.DrillConfig{MinFiles: 3, Command: "touch " + dirFile + ".ran"}, wantErr: "expected at least 3"},
+	}
+
+	for _, tt := range tests {
+		drill := tt.drill
+		backupCfg.Drill = &drill
+
+		result := executor.RunDrill(backupCfg)
+		if result.Backup != "site" || !strings.HasPrefix(result.Archive, "site-") {
+			t.Errorf("%s: result for %s/%s", tt.name, result.Backup, result.Archive)
+		}
+		if tt.wantErr == "" {
+			if result.Err != nil {
+				t.Errorf("%s: %v", tt.name, result.Err)
+			}
+		} else if result.Err == nil || !strings.Contains(result.Err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, result.Err, tt.wantErr)
+		}
+		if result.Files != 2 {
+			t.Errorf("%s: %d files restored, want 2", tt.name, result.Files)
+		}
+	}
+
+	if _, err := os.Stat(dirFile + ".ran"); !os.IsNotExist(err) {
+		t.Error("check command ran after the file count check failed")
+	}
+
+	// Директория с восстановленными файлами удаляется после проверки
+	data, err := os.ReadFile(dirFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(strings.TrimSpace(string(data))); !os.IsNotExist(err) {
+		t.Errorf("drill directory %s was not removed", strings.TrimSpace(string(data)))
+	}
+
+	if results := executor.DrillResults(); len(results) != len(tests) {
+		t.Errorf("DrillResults has %d results, want %d", len(results), len(tests))
+	}
+}
+
+func TestRunDrillBrokenArchive(t *testing.T) {
+	executor, backupCfg := newTestBackup(t, map[string]string{"index.html": "<html>"})
+
+	path := filepath.Join(executor.globalConfig.BackupDir, latestArchive(t, executor, backupCfg))
+	if err := os.WriteFile(path, []byte("not a gzip stream"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result := executor.RunDrill(backupCfg); result.Err == nil || !strings.Contains(result.Err.Error(), "failed to extract") {
+		t.Errorf("drill of broken archive: %v", result.Err)
+	}
+
+	backupCfg.Name = "other"
+	if result := executor.RunDrill(backupCfg); result.Err == nil || !strings.Contains(result.Err.Error(), "no archives found") {
+		t.Errorf("drill without archives: %v", result.Err)
+	}
+}
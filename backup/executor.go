@@ -7,15 +7,22 @@ import (
 	"path/filepath"
 	"time"
 
+	"goback/checksum"
 	"goback/compression"
 	"goback/config"
+	"goback/encryption"
 	"goback/hooks"
+	"goback/manifest"
 	"goback/retention"
+	"goback/signature"
+	"goback/storage"
 	"goback/utils"
 )
 
 type Executor struct {
 	globalConfig *config.GlobalConfig
+	drills       []DrillResult
+	storeStats   compression.StoreStats
 }
 
 func NewExecutor(globalConfig *config.GlobalConfig) *Executor {
@@ -24,22 +31,26 @@ func NewExecutor(globalConfig *config.GlobalConfig) *Executor {
 	}
 }
 
+// StoreStats возвращает общую статистику файлов, сохраненных без повторного сжатия за запуск
+func (e *Executor) StoreStats() compression.StoreStats {
+	return e.storeStats
+}
+
 func (e *Executor) ExecuteBackup(backupConfig *config.BackupConfig) error {
 	utils.PrintHeader("Starting backup: %s", backupConfig.Name)
 
 	// Выполняем локальные pre-hooks
 	if len(backupConfig.PreHooks) > 0 {
-		fmt.Printf("Running backup pre-hooks...\n")
+		utils.Printf("Running backup pre-hooks...\n")
 		if err := hooks.RunHooks(backupConfig.PreHooks); err != nil {
-			fmt.Printf("Warning: backup pre-hooks completed with errors\n")
+			utils.Printf("Warning: backup pre-hooks completed with errors\n")
 		}
 	}
 
 	// Определяем тип сжатия
-	compressionType := backupConfig.Compression
-	if compressionType == "" {
-		compressionType = e.globalConfig.DefaultCompression
-	}
+	compressionConfig := e.globalConfig.CompressionFor(backupConfig)
+	compressionType := compressionConfig.Type
+	encryptionConfig := e.globalConfig.EncryptionFor(backupConfig)
 
 	// Создаем временную директорию для бэкапа
 	tmpDir, err := os.MkdirTemp("", "backup-*")
@@ -49,14 +60,36 @@ func (e *Executor) ExecuteBackup(backupConfig *config.BackupConfig) error {
 	defer os.RemoveAll(tmpDir)
 
 	var sourcePath string
+	var captureStdout bool
+	var backupManifest *manifest.Manifest
+	var compressOptions compression.Options
 
 	// Выполняем бэкап
 	if backupConfig.SourceDir != "" {
-		// Бэкап директории
-		sourcePath = tmpDir
-		if err := CopyDirectory(backupConfig.SourceDir, sourcePath, backupConfig.ExcludePatterns); err != nil {
-			return fmt.Errorf("failed to copy directory: %w", err)
+		// Бэкап директории. Манифест зашифрованного бэкапа не пишется: он раскрыл бы имена файлов
+		if !encryptionConfig.Enabled() {
+			backupManifest = manifest.New(backupConfig.Name, backupConfig.SourceDir)
 		}
+		if backupConfig.Staging {
+			// Сначала копируем во временную директорию, чтобы архивировать неизменяемый снимок
+			sourcePath = tmpDir
+			if err := CopyDirectory(backupConfig.SourceDir, sourcePath, backupConfig.ExcludePatterns, backupManifest); err != nil {
+				return fmt.Errorf("failed to copy directory: %w", err)
+			}
+		} else {
+			// Архивируем напрямую из исходной директории, применяя exclude_patterns при обходе
+			sourcePath = backupConfig.SourceDir
+			excludePatterns := backupConfig.ExcludePatterns
+			compressOptions = compression.Options{
+				Exclude: func(relPath string) bool {
+					return shouldExclude(relPath, excludePatterns)
+				},
+				Manifest: backupManifest,
+			}
+		}
+	} else if backupConfig.Command != "" && backupConfig.Capture == config.CaptureStdout {
+		// stdout команды передается в компрессор при сжатии, без промежуточного файла
+		captureStdout = true
 	} else if backupConfig.Command != "" {
 		// Бэкап через команду
 		if err := ExecuteCommand(backupConfig.Command, backupConfig.OutputFile); err != nil {
@@ -72,56 +105,130 @@ func (e *Executor) ExecuteBackup(backupConfig *config.BackupConfig) error {
 		return fmt.Errorf("invalid backup configuration: no source_dir or command")
 	}
 
+	// Количество потоков для параллельного сжатия gzip и tar.gz и уровень сжатия
+	compressOptions.Threads = backupConfig.Threads
+	compressOptions.Level = e.globalConfig.CompressionLevelFor(backupConfig)
+	compressOptions.Filter = compressionConfig.Filter()
+	// Уже сжатые файлы сохраняются без повторного сжатия
+	var storeStats compression.StoreStats
+	compressOptions.Store = e.globalConfig.IncompressibleFor(backupConfig).StorePolicy()
+	compressOptions.StoreStats = &storeStats
+	// Архив шифруется перед записью на диск
+	compressOptions.Encryption = encryptionConfig.Recipients()
+	// Архив записывается томами .001, .002, ..., если указан split_size
+	compressOptions.SplitSize = backupConfig.SplitBytes()
+	// Архив и вспомогательные файлы записываются в хранилище
+	backend := e.globalConfig.Storage()
+	compressOptions.Storage = backend
+
 	// Создаем имя файла
-	now := time.Now()
+	now := time.Now().In(e.globalConfig.Location())
 	filename := utils.GenerateFilename(e.globalConfig.FilenameMask, backupConfig.Name, now)
 	ext := utils.GetExtension(compressionType)
+	if compressOptions.Filter != nil {
+		// Директория передается внешней программе как tar-поток
+		ext = compressOptions.Filter.ArchiveExtension(backupConfig.SourceDir != "")
+	}
 	if ext != "" {
 		filename += ext
 	}
-
-	// Создаем целевую директорию
-	backupSubDir := filepath.Join(e.globalConfig.BackupDir, backupConfig.Subdirectory)
-	if err := os.MkdirAll(backupSubDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+	if encryptionConfig.Enabled() {
+		filename += encryption.Extension
 	}
 
-	destinationPath := filepath.Join(backupSubDir, filename)
+	// Имя архива в хранилище; директория создается при записи
+	destinationPath := storage.Join(backupConfig.Subdirectory, filename)
 
 	// Применяем сжатие
-	compressor, err := compression.NewCompressor(compressionType)
+	compressor, err := compression.NewCompressor(compressionType, compressOptions)
 	if err != nil {
 		return fmt.Errorf("failed to create compressor: %w", err)
 	}
 
-	fmt.Printf("Compressing to %s...\n", destinationPath)
-	if err := compressor.Compress(sourcePath, destinationPath); err != nil {
-		return fmt.Errorf("failed to compress: %w", err)
+	if captureStdout {
+		streamCompressor, ok := compressor.(compression.StreamCompressor)
+		if !ok {
+			return fmt.Errorf("compression %s does not support capture: stdout", compressionType)
+		}
+
+		utils.Printf("Streaming command output to %s...\n", storage.Location(backend, destinationPath))
+		err := RunCaptureCommand(backupConfig.Command, func(r io.Reader) error {
+			return streamCompressor.CompressStream(r, backupConfig.Name, destinationPath)
+		})
+		if err != nil {
+			// Не оставляем обрезанный дамп, который выглядит как корректный архив
+			removeArchive(backend, destinationPath)
+			return fmt.Errorf("failed to capture command output: %w", err)
+		}
+	} else {
+		utils.Printf("Compressing to %s...\n", storage.Location(backend, destinationPath))
+		if err := compressor.Compress(sourcePath, destinationPath); err != nil {
+			return fmt.Errorf("failed to compress: %w", err)
+		}
 	}
 
-	utils.PrintSuccess("Backup created: %s", filename)
+	if storeStats.Files > 0 {
+		utils.Printf("Stored %d already compressed file(s) without recompression (%s)\n", storeStats.Files, utils.FormatSize(storeStats.Bytes))
+		e.storeStats.Add(storeStats)
+	}
 
-	// Применяем retention policy
-	retentionPolicy := e.globalConfig.Retention
-	if backupConfig.Retention != nil {
-		retentionPolicy = *backupConfig.Retention
+	// Записываем контрольную сумму рядом с архивом
+	if err := checksum.Write(backend, destinationPath); err != nil {
+		return err
 	}
 
-	fmt.Printf("Applying retention policy...\n")
-	if err := retention.ApplyRetention(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, retention.RetentionPolicy{
+	// Подписываем контрольные суммы, чтобы подмена архива в общем хранилище была обнаружена
+	if keyFile := e.globalConfig.Signing.KeyFile; keyFile != "" {
+		key, err := signature.LoadPrivateKey(keyFile)
+		if err != nil {
+			return err
+		}
+		if err := signature.Sign(backend, destinationPath, key); err != nil {
+			return err
+		}
+	}
+
+	// Для бэкапов директорий сохраняем манифест с содержимым архива
+	if backupManifest != nil {
+		if err := backupManifest.Write(backend, destinationPath+utils.ManifestExtension); err != nil {
+			return err
+		}
+	}
+
+	if files, err := storage.Volumes(backend, destinationPath); err == nil && compressOptions.SplitSize > 0 {
+		utils.PrintSuccess("Backup created: %s (%d volumes)", filename, len(files))
+	} else {
+		utils.PrintSuccess("Backup created: %s", filename)
+	}
+
+	// Применяем retention policy
+	retentionPolicy := e.globalConfig.RetentionFor(backupConfig)
+
+	utils.Printf("Applying retention policy...\n")
+	if err := retention.ApplyRetention(backend, backupConfig.Subdirectory, backupConfig.Name, retention.RetentionPolicy{
 		Daily:   retentionPolicy.Daily,
 		Weekly:  retentionPolicy.Weekly,
 		Monthly: retentionPolicy.Monthly,
 		Yearly:  retentionPolicy.Yearly,
 	}); err != nil {
-		fmt.Printf("Warning: retention policy failed: %v\n", err)
+		utils.Printf("Warning: retention policy failed: %v\n", err)
+	}
+
+	// Проверяем восстановление нового архива, если это указано в секции drill
+	if backupConfig.Drill != nil && backupConfig.Drill.AfterBackup {
+		result := e.RunDrill(backupConfig)
+		if result.Err != nil {
+			utils.PrintError("Restore drill failed: %v", result.Err)
+		} else {
+			utils.PrintSuccess("Restore drill passed: %d files restored", result.Files)
+		}
 	}
 
 	// Выполняем локальные post-hooks
 	if len(backupConfig.PostHooks) > 0 {
-		fmt.Printf("Running backup post-hooks...\n")
+		utils.Printf("Running backup post-hooks...\n")
 		if err := hooks.RunHooks(backupConfig.PostHooks); err != nil {
-			fmt.Printf("Warning: backup post-hooks completed with errors\n")
+			utils.Printf("Warning: backup post-hooks completed with errors\n")
 		}
 	}
 
@@ -129,6 +236,17 @@ func (e *Executor) ExecuteBackup(backupConfig *config.BackupConfig) error {
 	return nil
 }
 
+// removeArchive удаляет архив или все его тома
+func removeArchive(backend storage.Backend, name string) {
+	files, err := storage.Volumes(backend, name)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		backend.Delete(file)
+	}
+}
+
 func copyFileToTemp(src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
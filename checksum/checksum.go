@@ -0,0 +1,148 @@
+package checksum
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"goback/storage"
+	"goback/utils"
+)
+
+// Sum вычисляет SHA-256 объекта хранилища в шестнадцатеричном виде
+func Sum(b storage.Backend, name string) (string, error) {
+	file, err := b.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Write записывает контрольную сумму архива в файл <archive>.sha256
+// в формате, совместимом с sha256sum -c. Для архива, разбитого на тома, записывается
+// строка на каждый том
+func Write(b storage.Backend, name string) error {
+	files, err := storage.Volumes(b, name)
+	if err != nil {
+		return fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+
+	var lines strings.Builder
+	for _, file := range files {
+		sum, err := Sum(b, file)
+		if err != nil {
+			return fmt.Errorf("failed to calculate checksum: %w", err)
+		}
+		fmt.Fprintf(&lines, "%s  %s\n", sum, path.Base(file))
+	}
+
+	if err := storage.WriteFile(b, name+utils.ChecksumExtension, []byte(lines.String())); err != nil {
+		return fmt.Errorf("failed to write checksum file: %w", err)
+	}
+
+	return nil
+}
+
+// Exists проверяет наличие файла контрольной суммы у архива
+func Exists(b storage.Backend, name string) bool {
+	return storage.Exists(b, name+utils.ChecksumExtension)
+}
+
+// Verify сверяет архив с контрольной суммой из файла <archive>.sha256.
+// Тома архива сверяются по именам, поэтому пропавший или лишний том считается ошибкой
+func Verify(b storage.Backend, name string) error {
+	return verify(b, name, false)
+}
+
+// VerifyStrict сверяет архив так же, как Verify, но требует совпадения имени и для архива одним файлом.
+// Используется вместе с проверкой подписи, чтобы подписанные суммы нельзя было выдать
+// за контрольные суммы архива с другим именем, например более старого
+func VerifyStrict(b storage.Backend, name string) error {
+	return verify(b, name, true)
+}
+
+func verify(b storage.Backend, name string, strict bool) error {
+	expected, err := readSums(b, name+utils.ChecksumExtension)
+	if err != nil {
+		return err
+	}
+
+	files, err := storage.Volumes(b, name)
+	if err != nil {
+		return fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+
+	if len(files) != len(expected) {
+		return fmt.Errorf("checksum file lists %d file(s), found %d", len(expected), len(files))
+	}
+
+	for i, file := range files {
+		// Без strict имя архива одним файлом не сверяется, чтобы переименованный архив проходил проверку
+		if (strict || len(files) > 1) && expected[i].name != path.Base(file) {
+			return fmt.Errorf("checksum file lists %s, found %s", expected[i].name, path.Base(file))
+		}
+
+		actual, err := Sum(b, file)
+		if err != nil {
+			return fmt.Errorf("failed to calculate checksum: %w", err)
+		}
+
+		if actual != expected[i].sum {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path.Base(file), expected[i].sum, actual)
+		}
+	}
+
+	return nil
+}
+
+// fileSum - строка файла контрольных сумм
+type fileSum struct {
+	sum  string
+	name string
+}
+
+func readSums(b storage.Backend, sidecar string) ([]fileSum, error) {
+	file, err := b.Open(sidecar)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checksum file: %w", err)
+	}
+	defer file.Close()
+
+	var sums []fileSum
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid checksum file: %s", sidecar)
+		}
+
+		name := ""
+		if len(fields) > 1 {
+			name = strings.TrimPrefix(fields[1], "*")
+		}
+		sums = append(sums, fileSum{sum: strings.ToLower(fields[0]), name: name})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksum file: %w", err)
+	}
+
+	if len(sums) == 0 {
+		return nil, fmt.Errorf("empty checksum file: %s", sidecar)
+	}
+
+	return sums, nil
+}
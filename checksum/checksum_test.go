@@ -0,0 +1,84 @@
+package checksum
+
+import (
+	"testing"
+
+	"goback/storage"
+	"goback/utils"
+)
+
+func writeArchive(t *testing.T, b storage.Backend, name, content string) {
+	t.Helper()
+	if err := storage.WriteFile(b, name, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+	writeArchive(t, b, "app_2024-01-02_03-04-05.tar", "archive")
+
+	if err := Write(b, "app_2024-01-02_03-04-05.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if !Exists(b, "app_2024-01-02_03-04-05.tar") {
+		t.Fatal("checksum file not written")
+	}
+	if err := Verify(b, "app_2024-01-02_03-04-05.tar"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := VerifyStrict(b, "app_2024-01-02_03-04-05.tar"); err != nil {
+		t.Fatalf("VerifyStrict: %v", err)
+	}
+
+	writeArchive(t, b, "app_2024-01-02_03-04-05.tar", "tampered")
+	if err := Verify(b, "app_2024-01-02_03-04-05.tar"); err == nil {
+		t.Fatal("Verify accepted modified archive")
+	}
+}
+
+func TestVerifyStrictName(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+	writeArchive(t, b, "app_2024-01-01_00-00-00.tar", "old archive")
+	if err := Write(b, "app_2024-01-01_00-00-00.tar"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Старый архив вместе с суммами выдается за более новый
+	sums, err := storage.ReadFile(b, "app_2024-01-01_00-00-00.tar"+utils.ChecksumExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeArchive(t, b, "app_2024-02-01_00-00-00.tar", "old archive")
+	if err := storage.WriteFile(b, "app_2024-02-01_00-00-00.tar"+utils.ChecksumExtension, sums); err != nil {
+		t.Fatal(err)
+	}
+
+	// Без подписи переименованный архив проходит проверку
+	if err := Verify(b, "app_2024-02-01_00-00-00.tar"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := VerifyStrict(b, "app_2024-02-01_00-00-00.tar"); err == nil {
+		t.Fatal("VerifyStrict accepted archive with a different name")
+	}
+}
+
+func TestVerifyVolumes(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+	writeArchive(t, b, "app.tar.001", "first")
+	writeArchive(t, b, "app.tar.002", "second")
+
+	if err := Write(b, "app.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyStrict(b, "app.tar"); err != nil {
+		t.Fatalf("VerifyStrict: %v", err)
+	}
+
+	if err := b.Delete("app.tar.002"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(b, "app.tar"); err == nil {
+		t.Fatal("Verify accepted archive with a missing volume")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/config"
+	"goback/retention"
+	"goback/utils"
+)
+
+// subcommands содержит подкоманды, возвращающие код завершения процесса
+var subcommands = map[string]func(args []string) int{
+	"restore": runRestore,
+	"list":    runList,
+	"verify":  runVerify,
+	"diff":    runDiff,
+	"drill":   runDrill,
+	"keygen":  runKeygen,
+}
+
+// addConfigFlags регистрирует флаги пути к конфигурации
+func addConfigFlags(fs *flag.FlagSet, configPath *string) {
+	fs.StringVar(configPath, "config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+}
+
+// addIdentityFlags регистрирует флаги файлов закрытых ключей для расшифровки архивов
+func addIdentityFlags(fs *flag.FlagSet, identities *flagArray) {
+	fs.Var(identities, "identity", "Private key file to decrypt archives (can be specified multiple times)")
+	fs.Var(identities, "i", "Private key file to decrypt archives (short, can be specified multiple times)")
+}
+
+// checkSignature проверяет подпись контрольных сумм архива и соответствие архива им.
+// Возвращает false, если подпись не настроена или ошибка пропущена из-за --insecure
+func checkSignature(cfg *config.Config, path string, insecure bool) (bool, error) {
+	if !cfg.Global.Signing.Enabled() {
+		return false, nil
+	}
+
+	err := cfg.Global.Signing.Verify(cfg.Global.Storage(), path)
+	if err != nil && insecure {
+		utils.Printf("Warning: %s: %v (ignored with --insecure)\n", filepath.Base(path), err)
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// loadConfig загружает конфигурацию, выводя ошибку при неудаче
+func loadConfig(configPath string) (*config.Config, bool) {
+	utils.PrintHeader("Loading configuration from %s...", configPath)
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		utils.PrintError("Error loading config: %v", err)
+		return nil, false
+	}
+	return cfg, true
+}
+
+// findBackup ищет бэкап в конфигурации по имени
+func findBackup(cfg *config.Config, name string) (*config.BackupConfig, error) {
+	for i := range cfg.Backups {
+		if cfg.Backups[i].Name == name {
+			return &cfg.Backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("backup not found: %s", name)
+}
+
+// selectBackups возвращает бэкапы с указанными именами или все бэкапы конфигурации
+func selectBackups(cfg *config.Config, names []string) ([]config.BackupConfig, error) {
+	if len(names) == 0 {
+		return cfg.Backups, nil
+	}
+
+	var result []config.BackupConfig
+	var notFound []string
+	for _, name := range names {
+		backupCfg, err := findBackup(cfg, name)
+		if err != nil {
+			notFound = append(notFound, name)
+			continue
+		}
+		result = append(result, *backupCfg)
+	}
+
+	if len(notFound) > 0 {
+		return nil, fmt.Errorf("backup(s) not found: %s", strings.Join(notFound, ", "))
+	}
+
+	return result, nil
+}
+
+// selectorFlags - флаги выбора бэкапа по времени, общие для restore, list, verify и diff
+type selectorFlags struct {
+	at       string
+	before   string
+	latest   bool
+	timezone string
+}
+
+// addSelectorFlags регистрирует флаги выбора бэкапа по времени
+func addSelectorFlags(fs *flag.FlagSet, f *selectorFlags) {
+	fs.StringVar(&f.at, "at", "", "Select the newest archive not later than the given time (e.g. 2026-10-01T12:00)")
+	fs.StringVar(&f.before, "before", "", "Select the newest archive strictly before the given time")
+	fs.BoolVar(&f.latest, "latest", false, "Select the latest archive")
+	fs.StringVar(&f.timezone, "tz", "", "Timezone for --at and --before (default: global timezone)")
+}
+
+// isSet проверяет, что указан хотя бы один селектор
+func (f *selectorFlags) isSet() bool {
+	return f.at != "" || f.before != "" || f.latest
+}
+
+// location возвращает часовой пояс для времени в селекторах: из флага --tz или из конфигурации.
+// Время в именах архивов от флага не зависит (см. listArchives)
+func (f *selectorFlags) location(cfg *config.Config) (*time.Location, error) {
+	if f.timezone == "" {
+		return cfg.Global.Location(), nil
+	}
+
+	loc, err := time.LoadLocation(f.timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// selector разбирает значения флагов в селектор бэкапа
+func (f *selectorFlags) selector(loc *time.Location) (retention.Selector, error) {
+	count := 0
+	for _, set := range []bool{f.at != "", f.before != "", f.latest} {
+		if set {
+			count++
+		}
+	}
+	if count > 1 {
+		return retention.Selector{}, fmt.Errorf("--at, --before and --latest are mutually exclusive")
+	}
+
+	var selector retention.Selector
+	var err error
+
+	if f.at != "" {
+		if selector.At, err = utils.ParseTime(f.at, loc); err != nil {
+			return retention.Selector{}, err
+		}
+	}
+
+	if f.before != "" {
+		if selector.Before, err = utils.ParseTime(f.before, loc); err != nil {
+			return retention.Selector{}, err
+		}
+	}
+
+	return selector, nil
+}
+
+// listArchives возвращает архивы бэкапа от старых к новым. Время в именах архивов читается
+// в часовом поясе из конфигурации, в котором архивы были названы
+func listArchives(cfg *config.Config, backupCfg *config.BackupConfig) ([]retention.BackupFile, error) {
+	return retention.ListBackups(cfg.Global.Storage(), backupCfg.Subdirectory, backupCfg.Name, cfg.Global.Location())
+}
+
+// selectArchive выбирает архив бэкапа по селектору
+func selectArchive(cfg *config.Config, backupCfg *config.BackupConfig, selector retention.Selector) (retention.BackupFile, error) {
+	files, err := listArchives(cfg, backupCfg)
+	if err != nil {
+		return retention.BackupFile{}, err
+	}
+
+	if len(files) == 0 {
+		return retention.BackupFile{}, fmt.Errorf("no archives found for backup %s", backupCfg.Name)
+	}
+
+	file, err := retention.Select(files, selector)
+	if err != nil {
+		return retention.BackupFile{}, fmt.Errorf("backup %s: %w", backupCfg.Name, err)
+	}
+
+	return file, nil
+}
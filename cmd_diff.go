@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/compression"
+	"goback/manifest"
+	"goback/retention"
+	"goback/storage"
+	"goback/textdiff"
+	"goback/utils"
+)
+
+// runDiff сравнивает два архива одного бэкапа
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+
+	var configPath string
+	var identities flagArray
+	var backupName string
+	var selection selectorFlags
+	var maxSize string
+	var contextLines int
+	var insecure bool
+
+	addConfigFlags(fs, &configPath)
+	addIdentityFlags(fs, &identities)
+	fs.StringVar(&backupName, "backup", "", "Name of backup to compare")
+	fs.StringVar(&backupName, "b", "", "Name of backup to compare (short)")
+	addSelectorFlags(fs, &selection)
+	fs.StringVar(&maxSize, "max-size", "10MiB", "Maximum size of a command backup to show a text diff for")
+	fs.IntVar(&contextLines, "context", 3, "Number of context lines in the text diff")
+	fs.BoolVar(&insecure, "insecure", false, "Compare unsigned archives and archives with a mismatching signature")
+	fs.Parse(args)
+
+	positional := fs.Args()
+	if backupName == "" || len(positional) > 2 {
+		utils.PrintError("Usage: goback diff -b <name> [--at <time> | --before <time>] [--insecure] [<archive A> [<archive B>]]")
+		return 2
+	}
+
+	limit, err := utils.ParseSize(maxSize)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 2
+	}
+
+	cfg, ok := loadConfig(configPath)
+	if !ok {
+		return 1
+	}
+	cfg.Global.IdentityFiles = identities
+
+	backupCfg, err := findBackup(cfg, backupName)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	loc, err := selection.location(cfg)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	selector, err := selection.selector(loc)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	files, err := listArchives(cfg, backupCfg)
+	if err != nil {
+		utils.PrintError("Failed to list archives: %v", err)
+		return 1
+	}
+
+	older, newer, err := resolveDiffArchives(files, positional, selector, loc)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	// Подписи проверяются до чтения архивов, как при восстановлении
+	for _, archive := range []retention.BackupFile{older, newer} {
+		if _, err := checkSignature(cfg, archive.Path, insecure); err != nil {
+			utils.PrintError("Refusing to compare %s: %v (use --insecure to compare anyway)", filepath.Base(archive.Path), err)
+			return 1
+		}
+	}
+
+	utils.PrintHeader("Comparing %s -> %s", filepath.Base(older.Path), filepath.Base(newer.Path))
+
+	readOptions := cfg.Global.ReadOptionsFor(backupCfg)
+	if backupCfg.SourceDir != "" {
+		err = diffTrees(older.Path, newer.Path, readOptions)
+	} else {
+		err = diffDumps(older.Path, newer.Path, readOptions, limit, contextLines)
+	}
+
+	if err != nil {
+		utils.PrintError("Diff failed: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// resolveDiffArchives определяет сравниваемые архивы:
+// два аргумента - оба архива; один аргумент - он и архив по селектору (последний по умолчанию);
+// без аргументов - архив по селектору и предыдущий перед ним
+func resolveDiffArchives(files []retention.BackupFile, args []string, selector retention.Selector, loc *time.Location) (retention.BackupFile, retention.BackupFile, error) {
+	var older, newer retention.BackupFile
+	var err error
+
+	if len(files) == 0 {
+		return older, newer, fmt.Errorf("no archives found")
+	}
+
+	if len(args) == 2 {
+		if older, err = resolveArchive(files, args[0], loc); err != nil {
+			return older, newer, err
+		}
+		newer, err = resolveArchive(files, args[1], loc)
+		return older, newer, err
+	}
+
+	if newer, err = retention.Select(files, selector); err != nil {
+		return older, newer, err
+	}
+
+	if len(args) == 1 {
+		older, err = resolveArchive(files, args[0], loc)
+		return older, newer, err
+	}
+
+	// Предыдущий архив - самый новый строго раньше выбранного
+	older, err = retention.Select(files, retention.Selector{Before: newer.Time})
+	if err != nil {
+		return older, newer, fmt.Errorf("no archive before %s to compare with", filepath.Base(newer.Path))
+	}
+
+	return older, newer, nil
+}
+
+// resolveArchive находит архив по имени файла или по времени (последний не позже указанного)
+func resolveArchive(files []retention.BackupFile, value string, loc *time.Location) (retention.BackupFile, error) {
+	for _, file := range files {
+		if filepath.Base(file.Path) == value {
+			return file, nil
+		}
+	}
+
+	t, err := utils.ParseTime(value, loc)
+	if err != nil {
+		return retention.BackupFile{}, fmt.Errorf("archive %q not found: %w", value, err)
+	}
+
+	return retention.Select(files, retention.Selector{At: t})
+}
+
+// diffTrees выводит добавленные, удаленные и измененные файлы и директории между двумя архивами директории
+func diffTrees(olderPath, newerPath string, readOptions compression.ReadOptions) error {
+	// Манифесты используются, только если они есть у обоих архивов, иначе читаем сами архивы
+	useManifests := storage.Exists(readOptions.Storage, olderPath+utils.ManifestExtension) &&
+		storage.Exists(readOptions.Storage, newerPath+utils.ManifestExtension)
+
+	older, err := loadContents(olderPath, readOptions, useManifests)
+	if err != nil {
+		return err
+	}
+
+	newer, err := loadContents(newerPath, readOptions, useManifests)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, change := range manifest.Diff(older, newer) {
+		counts[change.Kind]++
+
+		// Директории выводятся с "/" на конце и без размера
+		path := change.Path
+		isDir := change.Type == manifest.TypeDirectory
+		if isDir {
+			path += "/"
+		}
+
+		switch change.Kind {
+		case manifest.ChangeAdded:
+			if isDir {
+				fmt.Printf("+ %s\n", path)
+			} else {
+				fmt.Printf("+ %s (%s)\n", path, utils.FormatSize(change.NewSize))
+			}
+		case manifest.ChangeRemoved:
+			if isDir {
+				fmt.Printf("- %s\n", path)
+			} else {
+				fmt.Printf("- %s (%s)\n", path, utils.FormatSize(change.OldSize))
+			}
+		case manifest.ChangeSkipped:
+			fmt.Printf("! %s (unreadable during the newer backup, not in the archive)\n", path)
+		case manifest.ChangeModified:
+			details := fmt.Sprintf("%s -> %s", utils.FormatSize(change.OldSize), utils.FormatSize(change.NewSize))
+			if change.OldMode != change.NewMode {
+				details += fmt.Sprintf(", mode %s -> %s", change.OldMode, change.NewMode)
+			}
+			fmt.Printf("M %s (%s)\n", path, details)
+		case manifest.ChangeMode:
+			fmt.Printf("m %s (mode %s -> %s)\n", path, change.OldMode, change.NewMode)
+		}
+	}
+
+	fmt.Printf("\n%d added, %d removed, %d modified, %d mode changed",
+		counts[manifest.ChangeAdded], counts[manifest.ChangeRemoved], counts[manifest.ChangeModified], counts[manifest.ChangeMode])
+	if counts[manifest.ChangeSkipped] > 0 {
+		fmt.Printf(", %d unreadable", counts[manifest.ChangeSkipped])
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// loadContents возвращает список файлов архива из манифеста или чтением самого архива
+func loadContents(path string, readOptions compression.ReadOptions, useManifest bool) (*manifest.Manifest, error) {
+	if useManifest {
+		return manifest.Load(readOptions.Storage, path+utils.ManifestExtension)
+	}
+
+	decompressor, err := compression.NewDecompressorFor(filepath.Base(path), readOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := manifest.New("", "")
+	files := make(map[string]manifest.File)
+	err = decompressor.Walk(path, func(entry compression.Entry, r io.Reader) error {
+		name := strings.TrimSuffix(entry.Name, "/")
+
+		switch {
+		case entry.Hardlink:
+			// Жесткая ссылка имеет то же содержимое, что и файл, на который она указывает
+			if file, ok := files[entry.Linkname]; ok {
+				file.Path = name
+				contents.AddFile(file)
+			}
+			return nil
+		case entry.Mode&os.ModeSymlink != 0:
+			contents.AddFile(manifest.File{
+				Path:    name,
+				Type:    manifest.TypeSymlink,
+				Mode:    manifest.FormatMode(entry.Mode),
+				ModTime: entry.ModTime,
+				Link:    entry.Linkname,
+			})
+			return nil
+		case entry.Mode.IsDir():
+			contents.AddFile(manifest.File{
+				Path:    name,
+				Type:    manifest.TypeDirectory,
+				Mode:    manifest.FormatMode(entry.Mode),
+				ModTime: entry.ModTime,
+			})
+			return nil
+		case !entry.Mode.IsRegular():
+			return nil
+		}
+
+		hash := sha256.New()
+		size, err := io.Copy(hash, r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name, err)
+		}
+
+		file := manifest.File{
+			Path:    name,
+			Type:    manifest.TypeFile,
+			Size:    size,
+			Mode:    manifest.FormatMode(entry.Mode),
+			ModTime: entry.ModTime,
+			SHA256:  hex.EncodeToString(hash.Sum(nil)),
+		}
+		contents.AddFile(file)
+		files[entry.Name] = file
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+
+	return contents, nil
+}
+
+// dumpContents - содержимое файла из архива бэкапа команды
+type dumpContents struct {
+	name string
+	data []byte
+	size int64
+	sum  string
+}
+
+// diffDumps выводит unified diff двух дампов; для дампов больше limit сравниваются только размер и хеш
+func diffDumps(olderPath, newerPath string, readOptions compression.ReadOptions, limit int64, contextLines int) error {
+	older, err := readDump(olderPath, readOptions, limit)
+	if err != nil {
+		return err
+	}
+
+	newer, err := readDump(newerPath, readOptions, limit)
+	if err != nil {
+		return err
+	}
+
+	if older.sum == newer.sum {
+		fmt.Printf("Dumps are identical (%s)\n", utils.FormatSize(newer.size))
+		return nil
+	}
+
+	if older.size > limit || newer.size > limit {
+		fmt.Printf("Dumps differ: %s -> %s (larger than --max-size %s, text diff skipped)\n",
+			utils.FormatSize(older.size), utils.FormatSize(newer.size), utils.FormatSize(limit))
+		return nil
+	}
+
+	diff, err := textdiff.Unified(
+		filepath.Base(olderPath)+"/"+older.name,
+		filepath.Base(newerPath)+"/"+newer.name,
+		older.data, newer.data, contextLines)
+	if errors.Is(err, textdiff.ErrTooManyChanges) {
+		fmt.Printf("Dumps differ: %s -> %s (more than %d changed lines, text diff skipped)\n",
+			utils.FormatSize(older.size), utils.FormatSize(newer.size), textdiff.MaxEdits)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(diff)
+	return nil
+}
+
+// readDump читает первый файл архива, сохраняя в памяти не больше limit байт
+func readDump(path string, readOptions compression.ReadOptions, limit int64) (*dumpContents, error) {
+	decompressor, err := compression.NewDecompressorFor(filepath.Base(path), readOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var dump *dumpContents
+	err = decompressor.Walk(path, func(entry compression.Entry, r io.Reader) error {
+		if dump != nil || !entry.Mode.IsRegular() {
+			return nil
+		}
+
+		var buf bytes.Buffer
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(hash, &limitedBuffer{buf: &buf, limit: limit}), r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name, err)
+		}
+
+		dump = &dumpContents{
+			name: entry.Name,
+			data: buf.Bytes(),
+			size: size,
+			sum:  hex.EncodeToString(hash.Sum(nil)),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+
+	if dump == nil {
+		return nil, fmt.Errorf("archive %s is empty", filepath.Base(path))
+	}
+
+	return dump, nil
+}
+
+// limitedBuffer сохраняет только первые limit байт, принимая весь поток
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if rest := b.limit - int64(b.buf.Len()); rest > 0 {
+		if int64(len(p)) > rest {
+			b.buf.Write(p[:rest])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goback/compression"
+	"goback/manifest"
+	"goback/retention"
+	"goback/storage"
+)
+
+func TestResolveDiffArchives(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 3, 0, 0, 0, time.UTC) }
+	var files []retention.BackupFile
+	for _, d := range []int{1, 2, 3} {
+		files = append(files, retention.BackupFile{
+			Path: "app/app-" + day(d).Format("20060102150405") + ".tar.gz",
+			Time: day(d),
+		})
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		selector retention.Selector
+		older    string
+		newer    string
+		wantErr  bool
+	}{
+		{name: "latest and previous", older: "app-20261002030000.tar.gz", newer: "app-20261003030000.tar.gz"},
+		{
+			name:     "selected and previous",
+			selector: retention.Selector{At: day(2).Add(time.Hour)},
+			older:    "app-20261001030000.tar.gz",
+			newer:    "app-20261002030000.tar.gz",
+		},
+		{name: "no previous", selector: retention.Selector{At: day(1)}, wantErr: true},
+		{name: "one file name", args: []string{"app-20261001030000.tar.gz"}, older: "app-20261001030000.tar.gz", newer: "app-20261003030000.tar.gz"},
+		{
+			name:     "one time with selector",
+			args:     []string{"2026-10-01 12:00"},
+			selector: retention.Selector{Before: day(3)},
+			older:    "app-20261001030000.tar.gz",
+			newer:    "app-20261002030000.tar.gz",
+		},
+		{name: "two names", args: []string{"app-20261003030000.tar.gz", "app-20261001030000.tar.gz"}, older: "app-20261003030000.tar.gz", newer: "app-20261001030000.tar.gz"},
+		{name: "first of two before all archives", args: []string{"2026-10-01", "20261002030000"}, wantErr: true},
+		{name: "two timestamps", args: []string{"20261001030000", "2026-10-02 23:59"}, older: "app-20261001030000.tar.gz", newer: "app-20261002030000.tar.gz"},
+		{name: "unknown name", args: []string{"app-20261005030000.tar.gz"}, wantErr: true},
+		{name: "time before first", args: []string{"2026-09-30"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		older, newer, err := resolveDiffArchives(files, tt.args, tt.selector, time.UTC)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: resolved %s -> %s, want error", tt.name, older.Path, newer.Path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if filepath.Base(older.Path) != tt.older || filepath.Base(newer.Path) != tt.newer {
+			t.Errorf("%s: resolved %s -> %s, want %s -> %s", tt.name, older.Path, newer.Path, tt.older, tt.newer)
+		}
+	}
+
+	if _, _, err := resolveDiffArchives(nil, nil, retention.Selector{}, time.UTC); err == nil {
+		t.Error("resolveDiffArchives on empty list returned no error")
+	}
+}
+
+func TestLoadContentsDirectories(t *testing.T) {
+	source := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "uploads", "2026"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "index.html"), []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := storage.NewLocal(t.TempDir())
+	for _, name := range []string{"site.tar.gz", "site.zip"} {
+		compressionType := "tar.gz"
+		if filepath.Ext(name) == ".zip" {
+			compressionType = "zip"
+		}
+		c, err := compression.NewCompressor(compressionType, compression.Options{Storage: b})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Compress(source, name); err != nil {
+			t.Fatal(err)
+		}
+
+		// Пустые директории читаются из архива так же, как из манифеста
+		contents, err := loadContents(name, compression.ReadOptions{Storage: b}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types := make(map[string]string)
+		for _, file := range contents.Files {
+			types[file.Path] = file.Type
+		}
+		want := map[string]string{"index.html": manifest.TypeFile, "uploads": manifest.TypeDirectory, "uploads/2026": manifest.TypeDirectory}
+		if len(types) != len(want) {
+			t.Errorf("%s: entries %v, want %v", name, types, want)
+		}
+		for path, typ := range want {
+			if types[path] != typ {
+				t.Errorf("%s: %s has type %q, want %q", name, path, types[path], typ)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"goback/backup"
+	"goback/config"
+	"goback/utils"
+)
+
+// runDrill выполняет проверки восстановления бэкапов
+func runDrill(args []string) int {
+	fs := flag.NewFlagSet("drill", flag.ExitOnError)
+
+	var configPath string
+	var identities flagArray
+	var backupNames flagArray
+
+	addConfigFlags(fs, &configPath)
+	addIdentityFlags(fs, &identities)
+	fs.Var(&backupNames, "backup", "Name of backup to drill (can be specified multiple times)")
+	fs.Var(&backupNames, "b", "Name of backup to drill (short, can be specified multiple times)")
+	fs.Parse(args)
+
+	cfg, ok := loadConfig(configPath)
+	if !ok {
+		return 1
+	}
+	cfg.Global.IdentityFiles = identities
+
+	backups, err := selectBackups(cfg, backupNames)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	// Без указания имен проверяются только бэкапы с секцией drill
+	if len(backupNames) == 0 {
+		var withDrill []config.BackupConfig
+		for _, backupCfg := range backups {
+			if backupCfg.Drill != nil {
+				withDrill = append(withDrill, backupCfg)
+			}
+		}
+		backups = withDrill
+	}
+
+	if len(backups) == 0 {
+		utils.PrintError("No backups with a drill section found")
+		return 1
+	}
+
+	executor := backup.NewExecutor(&cfg.Global)
+	for i := range backups {
+		utils.PrintHeaderf("\n[%d/%d] Restore drill: %s\n", i+1, len(backups), backups[i].Name)
+		executor.RunDrill(&backups[i])
+	}
+
+	if !printDrillReport(executor.DrillResults()) {
+		return 1
+	}
+
+	return 0
+}
+
+// printDrillReport выводит результаты проверок восстановления и возвращает false, если есть проваленные
+func printDrillReport(results []backup.DrillResult) bool {
+	if len(results) == 0 {
+		return true
+	}
+
+	utils.PrintHeader("\n=== Restore drills ===")
+
+	passed := true
+	for _, result := range results {
+		name := result.Backup
+		if result.Archive != "" {
+			name += " (" + result.Archive + ")"
+		}
+
+		if result.Err != nil {
+			utils.PrintError("FAILED %s: %v", name, result.Err)
+			passed = false
+			continue
+		}
+		utils.PrintSuccess("PASSED %s: %d files in %s", name, result.Files, result.Duration.Round(time.Millisecond))
+	}
+
+	return passed
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"goback/encryption"
+	"goback/signature"
+	"goback/utils"
+)
+
+// runKeygen создает пару ключей X25519 для шифрования архивов или, с --sign, ключ подписи Ed25519.
+// Закрытый ключ пишется в файл (или в stdout), открытый выводится для секции encryption.recipients
+// или signing.public_keys
+func runKeygen(args []string) int {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+
+	var output string
+	var sign bool
+
+	fs.StringVar(&output, "output", "", "Write the private key to the file instead of stdout")
+	fs.StringVar(&output, "o", "", "Write the private key to the file instead of stdout (short)")
+	fs.BoolVar(&sign, "sign", false, "Generate an Ed25519 signing key instead of an encryption key")
+	fs.Parse(args)
+
+	var privateKey, publicKey string
+	if sign {
+		key, err := signature.GenerateKey()
+		if err != nil {
+			utils.PrintError("Failed to generate key: %v", err)
+			return 1
+		}
+		privateKey = signature.FormatPrivateKey(key)
+		publicKey = signature.FormatPublicKey(key.Public().(ed25519.PublicKey))
+	} else {
+		identity, err := encryption.GenerateIdentity()
+		if err != nil {
+			utils.PrintError("Failed to generate key: %v", err)
+			return 1
+		}
+		privateKey = identity.String()
+		publicKey = identity.Recipient().String()
+	}
+
+	var w io.Writer = os.Stdout
+	if output == "" {
+		// В stdout пишется только закрытый ключ
+		utils.Output = os.Stderr
+	} else {
+		// Существующий файл ключей не перезаписывается, иначе архивы станет нечем расшифровать
+		file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			utils.PrintError("Failed to create key file: %v", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	_, err := fmt.Fprintf(w, "# created: %s\n# public key: %s\n%s\n", time.Now().Format(time.RFC3339), publicKey, privateKey)
+	if err != nil {
+		utils.PrintError("Failed to write key: %v", err)
+		return 1
+	}
+
+	utils.PrintSuccess("Public key: %s", publicKey)
+	return 0
+}
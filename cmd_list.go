@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"goback/compression"
+	"goback/config"
+	"goback/retention"
+	"goback/storage"
+	"goback/utils"
+)
+
+// listedBackup описывает архив в выводе подкоманды list
+type listedBackup struct {
+	Backup      string    `json:"backup"`
+	File        string    `json:"file"`
+	Path        string    `json:"path"`
+	Time        time.Time `json:"time"`
+	Size        int64     `json:"size"`
+	Volumes     int       `json:"volumes,omitempty"`
+	Compression string    `json:"compression"`
+	Encrypted   bool      `json:"encrypted"`
+	Retention   []string  `json:"retention"`
+}
+
+// runList выводит список архивов бэкапов с уровнями хранения
+func runList(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+
+	var configPath string
+	var backupNames flagArray
+	var asJSON bool
+	var selection selectorFlags
+
+	addConfigFlags(fs, &configPath)
+	fs.Var(&backupNames, "backup", "Name of backup to list (can be specified multiple times)")
+	fs.Var(&backupNames, "b", "Name of backup to list (short, can be specified multiple times)")
+	fs.BoolVar(&asJSON, "json", false, "Print the list as JSON")
+	addSelectorFlags(fs, &selection)
+	fs.Parse(args)
+
+	// В режиме JSON в stdout выводятся только данные
+	if asJSON {
+		utils.Output = os.Stderr
+	}
+
+	cfg, ok := loadConfig(configPath)
+	if !ok {
+		return 1
+	}
+
+	backups, err := selectBackups(cfg, backupNames)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	loc, err := selection.location(cfg)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	// Без селекторов выводятся все архивы, иначе только выбранный архив каждого бэкапа
+	var selector *retention.Selector
+	if selection.isSet() {
+		parsed, err := selection.selector(loc)
+		if err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+		selector = &parsed
+	}
+
+	result := []listedBackup{}
+	for i := range backups {
+		listed, err := listBackup(cfg, &backups[i], selector)
+		if err != nil {
+			utils.PrintError("Failed to list backup %s: %v", backups[i].Name, err)
+			return 1
+		}
+		result = append(result, listed...)
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			utils.PrintError("Failed to encode JSON: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	printBackupList(backups, result)
+	return 0
+}
+
+// listBackup собирает архивы одного бэкапа и уровни хранения, которые их удерживают.
+// Если передан селектор, возвращается только выбранный им архив
+func listBackup(cfg *config.Config, backupCfg *config.BackupConfig, selector *retention.Selector) ([]listedBackup, error) {
+	files, err := listArchives(cfg, backupCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := cfg.Global.RetentionFor(backupCfg)
+	tiers := retention.ClassifyBackups(files, retention.RetentionPolicy{
+		Daily:   policy.Daily,
+		Weekly:  policy.Weekly,
+		Monthly: policy.Monthly,
+		Yearly:  policy.Yearly,
+	})
+
+	if selector != nil {
+		selected, err := retention.Select(files, *selector)
+		if err != nil {
+			return nil, err
+		}
+		files = []retention.BackupFile{selected}
+	}
+
+	filter := cfg.Global.CompressionFor(backupCfg).Filter()
+	backend := cfg.Global.Storage()
+	result := make([]listedBackup, 0, len(files))
+	for _, file := range files {
+		name := filepath.Base(file.Path)
+		kept := tiers[file.Path]
+		if kept == nil {
+			kept = []string{}
+		}
+		result = append(result, listedBackup{
+			Backup:      backupCfg.Name,
+			File:        name,
+			Path:        storage.Location(backend, file.Path),
+			Time:        file.Time,
+			Size:        file.Size,
+			Volumes:     len(file.Volumes),
+			Compression: compression.DetectCompression(name, filter),
+			Encrypted:   compression.IsEncrypted(name),
+			Retention:   kept,
+		})
+	}
+
+	return result, nil
+}
+
+func printBackupList(backups []config.BackupConfig, listed []listedBackup) {
+	for _, backupCfg := range backups {
+		utils.PrintHeader("\n%s (%s)", backupCfg.Name, backupCfg.Subdirectory)
+
+		var items []listedBackup
+		for _, item := range listed {
+			if item.Backup == backupCfg.Name {
+				items = append(items, item)
+			}
+		}
+
+		if len(items) == 0 {
+			utils.Printf("No archives found\n")
+			continue
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIMESTAMP\tSIZE\tCOMPRESSION\tRETENTION\tFILE")
+		for _, item := range items {
+			// Архивы, не удерживаемые ни одним уровнем, удалятся при следующем запуске
+			kept := strings.Join(item.Retention, ",")
+			if kept == "" {
+				kept = "expired"
+			}
+			kind := item.Compression
+			if item.Encrypted {
+				kind += "+enc"
+			}
+			file := item.File
+			if item.Volumes > 0 {
+				file = fmt.Sprintf("%s (%d volumes)", item.File, item.Volumes)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				item.Time.Format("2006-01-02 15:04:05"), utils.FormatSize(item.Size), kind, kept, file)
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+
+	"goback/backup"
+	"goback/compression"
+	"goback/utils"
+)
+
+// runRestore распаковывает архив бэкапа в целевую директорию
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+
+	var configPath string
+	var identities flagArray
+	var backupName string
+	var selection selectorFlags
+	var targetDir string
+	var toStdout bool
+	var paths flagArray
+	var insecure bool
+
+	addConfigFlags(fs, &configPath)
+	addIdentityFlags(fs, &identities)
+	fs.StringVar(&backupName, "backup", "", "Name of backup to restore")
+	fs.StringVar(&backupName, "b", "", "Name of backup to restore (short)")
+	addSelectorFlags(fs, &selection)
+	fs.StringVar(&targetDir, "to", "", "Directory to extract the archive into")
+	fs.BoolVar(&toStdout, "to-stdout", false, "Write the restored file (dump) to stdout")
+	fs.Var(&paths, "path", "Extract only entries matching the glob pattern, ** matches any depth (can be specified multiple times)")
+	fs.BoolVar(&insecure, "insecure", false, "Restore unsigned archives and archives with a mismatching signature")
+	fs.Parse(args)
+
+	if backupName == "" || (targetDir != "" && toStdout) {
+		utils.PrintError("Usage: goback restore -b <name> [--at <time> | --before <time> | --latest] [--path <pattern>] [--to <dir> | --to-stdout] [--insecure]")
+		return 2
+	}
+
+	// В stdout пишутся только данные дампа
+	if toStdout {
+		utils.Output = os.Stderr
+	}
+
+	cfg, ok := loadConfig(configPath)
+	if !ok {
+		return 1
+	}
+	cfg.Global.IdentityFiles = identities
+
+	backupCfg, err := findBackup(cfg, backupName)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	// Без --to и --to-stdout дамп передается в restore_command
+	if targetDir == "" && !toStdout && backupCfg.RestoreCommand == "" {
+		utils.PrintError("Backup %s has no restore_command: specify --to <dir> or --to-stdout", backupName)
+		return 2
+	}
+
+	loc, err := selection.location(cfg)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	selector, err := selection.selector(loc)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	archive, err := selectArchive(cfg, backupCfg, selector)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	// Подпись проверяется до распаковки, чтобы подмененный архив не попал в целевую директорию
+	if _, err := checkSignature(cfg, archive.Path, insecure); err != nil {
+		utils.PrintError("Refusing to restore %s: %v (use --insecure to restore anyway)", filepath.Base(archive.Path), err)
+		return 1
+	}
+
+	decompressor, err := compression.NewDecompressorFor(filepath.Base(archive.Path), cfg.Global.ReadOptionsFor(backupCfg))
+	if err != nil {
+		utils.PrintError("Failed to create decompressor: %v", err)
+		return 1
+	}
+
+	name := filepath.Base(archive.Path)
+
+	switch {
+	case toStdout:
+		utils.PrintHeader("Writing %s to stdout...", name)
+		entry, err := compression.DecompressFile(decompressor, archive.Path, paths, os.Stdout)
+		if err != nil {
+			utils.PrintError("Restore failed: %v", err)
+			return 1
+		}
+		utils.PrintSuccess("Restore completed: %s", entry.Name)
+
+	case targetDir != "":
+		utils.PrintHeader("Restoring %s to %s...", name, targetDir)
+		if len(paths) > 0 {
+			count, err := compression.DecompressPaths(decompressor, archive.Path, targetDir, paths)
+			if err != nil {
+				utils.PrintError("Restore failed: %v", err)
+				return 1
+			}
+			if count == 0 {
+				utils.PrintError("No entries matching %s found in %s", paths.String(), name)
+				return 1
+			}
+			utils.Printf("Extracted %d entries\n", count)
+		} else if err := decompressor.Decompress(archive.Path, targetDir); err != nil {
+			utils.PrintError("Restore failed: %v", err)
+			return 1
+		}
+		utils.PrintSuccess("Restore completed: %s", targetDir)
+
+	default:
+		utils.PrintHeader("Restoring %s with restore command...", name)
+		err := backup.RunRestoreCommand(backupCfg.RestoreCommand, func(w io.Writer) error {
+			_, err := compression.DecompressFile(decompressor, archive.Path, paths, w)
+			return err
+		})
+		if err != nil {
+			utils.PrintError("Restore failed: %v", err)
+			return 1
+		}
+		utils.PrintSuccess("Restore completed: %s", backupCfg.Name)
+	}
+
+	return 0
+}
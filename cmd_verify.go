@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+
+	"goback/checksum"
+	"goback/compression"
+	"goback/config"
+	"goback/retention"
+	"goback/utils"
+)
+
+// runVerify проверяет целостность архивов, читая их без распаковки на диск
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+
+	var configPath string
+	var identities flagArray
+	var backupNames flagArray
+	var all bool
+	var selection selectorFlags
+	var insecure bool
+
+	addConfigFlags(fs, &configPath)
+	addIdentityFlags(fs, &identities)
+	fs.Var(&backupNames, "backup", "Name of backup to verify (can be specified multiple times)")
+	fs.Var(&backupNames, "b", "Name of backup to verify (short, can be specified multiple times)")
+	fs.BoolVar(&all, "all", false, "Verify all stored archives instead of the latest one")
+	addSelectorFlags(fs, &selection)
+	fs.BoolVar(&insecure, "insecure", false, "Report unsigned archives and signature mismatches as warnings")
+	fs.Parse(args)
+
+	cfg, ok := loadConfig(configPath)
+	if !ok {
+		return 1
+	}
+	cfg.Global.IdentityFiles = identities
+
+	backups, err := selectBackups(cfg, backupNames)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	if all && selection.isSet() {
+		utils.PrintError("--all cannot be combined with --at, --before or --latest")
+		return 2
+	}
+
+	loc, err := selection.location(cfg)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	selector, err := selection.selector(loc)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	okCount := 0
+	failedCount := 0
+
+	for _, backupCfg := range backups {
+		utils.PrintHeader("\nVerifying backup: %s", backupCfg.Name)
+
+		files, err := listArchives(cfg, &backupCfg)
+		if err != nil {
+			utils.PrintError("Failed to list archives: %v", err)
+			failedCount++
+			continue
+		}
+
+		if len(files) == 0 {
+			utils.PrintError("No archives found")
+			failedCount++
+			continue
+		}
+
+		if !all {
+			file, err := retention.Select(files, selector)
+			if err != nil {
+				utils.PrintError("%v", err)
+				failedCount++
+				continue
+			}
+			files = []retention.BackupFile{file}
+		}
+
+		readOptions := cfg.Global.ReadOptionsFor(&backupCfg)
+		for _, file := range files {
+			if err := verifyArchive(cfg, file, readOptions, insecure); err != nil {
+				utils.PrintError("FAILED %s: %v", filepath.Base(file.Path), err)
+				failedCount++
+				continue
+			}
+			okCount++
+		}
+	}
+
+	utils.PrintHeader("\n=== Summary ===")
+	utils.PrintSuccess("OK: %d", okCount)
+	if failedCount > 0 {
+		utils.PrintError("Failed: %d", failedCount)
+		return 1
+	}
+
+	return 0
+}
+
+func verifyArchive(cfg *config.Config, file retention.BackupFile, readOptions compression.ReadOptions, insecure bool) error {
+	name := filepath.Base(file.Path)
+
+	signed, err := checkSignature(cfg, file.Path, insecure)
+	if err != nil {
+		return err
+	}
+
+	// Контрольная сумма выявляет повреждения, которые не видны формату архива (например, в none).
+	// Подписанный архив уже сверен с контрольной суммой при проверке подписи
+	switch {
+	case signed:
+	case checksum.Exists(readOptions.Storage, file.Path):
+		if err := checksum.Verify(readOptions.Storage, file.Path); err != nil {
+			return err
+		}
+	default:
+		utils.Printf("Warning: no checksum file for %s\n", name)
+	}
+
+	decompressor, err := compression.NewDecompressorFor(name, readOptions)
+	if err != nil {
+		return err
+	}
+
+	count, err := compression.Verify(decompressor, file.Path)
+	if err != nil {
+		return err
+	}
+
+	utils.PrintSuccess("OK %s (%d entries)", name, count)
+	return nil
+}
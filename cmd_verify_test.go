@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goback/checksum"
+	"goback/compression"
+	"goback/config"
+	"goback/retention"
+	"goback/utils"
+)
+
+func TestVerifyArchive(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "index.html"), []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Global: config.GlobalConfig{BackupDir: t.TempDir()}}
+	backupCfg := config.BackupConfig{Name: "site", Compression: config.CompressionConfig{Type: "tar"}}
+	readOptions := cfg.Global.ReadOptionsFor(&backupCfg)
+	file := retention.BackupFile{Path: "site/site-20261018120000.tar"}
+
+	c, err := compression.NewCompressor("tar", compression.Options{Storage: readOptions.Storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Compress(source, file.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := checksum.Write(readOptions.Storage, file.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyArchive(cfg, file, readOptions, false); err != nil {
+		t.Fatalf("intact archive: %v", err)
+	}
+
+	// Измененное содержимое файла tar читается без ошибок, его выявляет контрольная сумма
+	path := filepath.Join(cfg.Global.BackupDir, filepath.FromSlash(file.Path))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[512] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyArchive(cfg, file, readOptions, false); err == nil {
+		t.Error("archive with changed content passed verify")
+	}
+
+	// Обрезанный архив без файла контрольной суммы выявляет чтение архива
+	if err := os.Remove(path + utils.ChecksumExtension); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data[:515], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyArchive(cfg, file, readOptions, false); err == nil {
+		t.Error("truncated archive passed verify")
+	}
+}
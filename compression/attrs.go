@@ -0,0 +1,43 @@
+package compression
+
+import (
+	"fmt"
+	"os"
+)
+
+// CopyAttributes переносит на копию dst владельца (только при запуске от root), расширенные атрибуты,
+// права и время модификации элемента src, чтобы копия попадала в архив так же, как оригинал.
+// Директории нужно обрабатывать после копирования их содержимого, иначе время модификации изменится,
+// а права без записи не дадут создать файлы внутри
+func CopyAttributes(src, dst string, info os.FileInfo) error {
+	if uid, gid, ok := fileOwner(info); ok && os.Geteuid() == 0 {
+		if err := os.Lchown(dst, uid, gid); err != nil {
+			return fmt.Errorf("failed to chown %s: %w", dst, err)
+		}
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		if err := lchtimes(dst, info.ModTime()); err != nil {
+			return fmt.Errorf("failed to set modification time for %s: %w", dst, err)
+		}
+		return nil
+	}
+
+	// Как и при архивировании, атрибуты файловой системы без их поддержки пропускаются
+	xattrs, _ := readXattrs(src)
+	for name, value := range xattrs {
+		if err := writeXattr(dst, name, value); err != nil {
+			return fmt.Errorf("failed to set extended attribute %s on %s: %w", name, dst, err)
+		}
+	}
+
+	// Права выставляются после смены владельца, которая сбрасывает setuid/setgid
+	if err := os.Chmod(dst, fileMode(info.Mode())); err != nil {
+		return fmt.Errorf("failed to chmod %s: %w", dst, err)
+	}
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to set modification time for %s: %w", dst, err)
+	}
+
+	return nil
+}
@@ -3,19 +3,30 @@ package compression
 import (
 	"archive/tar"
 	"archive/zip"
-	"compress/gzip"
+	"compress/flate"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"goback/manifest"
 )
 
 type Compressor interface {
 	Compress(source, destination string) error
 }
 
-type GzipCompressor struct{}
+// StreamCompressor сжимает поток данных без исходного файла (например, stdout команды).
+// name - имя файла внутри архива
+type StreamCompressor interface {
+	CompressStream(r io.Reader, name, destination string) error
+}
+
+type GzipCompressor struct {
+	Options Options
+}
 
 func (c *GzipCompressor) Compress(source, destination string) error {
 	srcFile, err := os.Open(source)
@@ -24,183 +35,228 @@ func (c *GzipCompressor) Compress(source, destination string) error {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(destination)
+	// Сохраняем имя и время модификации исходного файла для восстановления
+	modTime := time.Now()
+	if info, err := srcFile.Stat(); err == nil {
+		modTime = info.ModTime()
+	}
+
+	return c.writeGzip(srcFile, filepath.Base(source), modTime, destination)
+}
+
+func (c *GzipCompressor) CompressStream(r io.Reader, name, destination string) error {
+	return c.writeGzip(r, name, time.Now(), destination)
+}
+
+func (c *GzipCompressor) writeGzip(r io.Reader, name string, modTime time.Time, destination string) error {
+	dstFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer dstFile.Close()
+	defer dstFile.Abort()
 
-	writer := gzip.NewWriter(dstFile)
-	defer writer.Close()
+	writer := newGzipWriter(dstFile, c.Options, name, modTime, false)
 
-	_, err = io.Copy(writer, srcFile)
-	if err != nil {
+	if _, err := io.Copy(writer, r); err != nil {
 		return fmt.Errorf("failed to compress: %w", err)
 	}
 
-	return nil
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	return dstFile.Close()
 }
 
-type ZipCompressor struct{}
+type ZipCompressor struct {
+	Options Options
+}
 
 func (c *ZipCompressor) Compress(source, destination string) error {
-	zipFile, err := os.Create(destination)
+	zipFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create zip file: %w", err)
 	}
-	defer zipFile.Close()
+	defer zipFile.Abort()
 
-	writer := zip.NewWriter(zipFile)
-	defer writer.Close()
+	if err := c.writeZip(zipFile, source); err != nil {
+		return err
+	}
 
-	// Если source - это файл
-	info, err := os.Stat(source)
+	return zipFile.Close()
+}
+
+func (c *ZipCompressor) CompressStream(r io.Reader, name, destination string) error {
+	zipFile, err := createArchive(destination, c.Options)
 	if err != nil {
-		return fmt.Errorf("failed to stat source: %w", err)
+		return fmt.Errorf("failed to create zip file: %w", err)
 	}
+	defer zipFile.Abort()
 
-	if !info.IsDir() {
-		return c.addFileToZip(writer, source, filepath.Base(source))
+	writer := c.newZipWriter(zipFile)
+
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   c.method(),
+		Modified: time.Now(),
 	}
+	header.SetMode(0644)
 
-	// Если source - это директория
-	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			// Пропускаем файлы/директории, к которым нет доступа
-			return nil
-		}
+	w, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
 
-		// Пропускаем директории
-		if info.IsDir() {
-			return nil
-		}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to compress: %w", err)
+	}
 
-		// Пропускаем специальные файлы (socket, named pipe, device files)
-		mode := info.Mode()
-		if mode&os.ModeSocket != 0 || mode&os.ModeNamedPipe != 0 || mode&os.ModeDevice != 0 {
-			return nil
-		}
+	if err := writer.Close(); err != nil {
+		return err
+	}
 
-		// Проверяем, является ли это симлинком, указывающим на директорию
-		if mode&os.ModeSymlink != 0 {
-			// Проверяем, куда указывает симлинк
-			target, err := os.Readlink(path)
-			if err != nil {
-				// Не удалось прочитать симлинк, пропускаем
-				return nil
-			}
-			// Получаем абсолютный путь цели
-			if !filepath.IsAbs(target) {
-				target = filepath.Join(filepath.Dir(path), target)
-			}
-			// Проверяем, находится ли цель внутри исходной директории
-			relTarget, err := filepath.Rel(source, target)
-			if err != nil || strings.HasPrefix(relTarget, "..") {
-				// Цель находится вне исходной директории, пропускаем
-				return nil
-			}
-			// Проверяем, является ли цель директорией
-			// Если цель не существует, просто пропускаем (не добавляем битый симлинк)
-			targetInfo, err := os.Stat(target)
-			if err != nil {
-				// Цель не существует, пропускаем
-				return nil
-			}
-			if targetInfo.IsDir() {
-				// Симлинк указывает на директорию, пропускаем
-				return nil
-			}
-		}
+	return zipFile.Close()
+}
 
-		relPath, err := filepath.Rel(source, path)
-		if err != nil {
-			return err
-		}
+// newZipWriter создает zip-писатель, сжимающий элементы с уровнем из настроек
+func (c *ZipCompressor) newZipWriter(w io.Writer) *zip.Writer {
+	writer := zip.NewWriter(w)
 
-		return c.addFileToZip(writer, path, relPath)
+	level := c.Options.level()
+	writer.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
 	})
+
+	return writer
 }
 
-func (c *ZipCompressor) addFileToZip(writer *zip.Writer, filePath, zipPath string) error {
-	// Используем Lstat, чтобы не следовать симлинкам
-	info, err := os.Lstat(filePath)
+// method возвращает метод сжатия элементов: при уровне store данные сохраняются без сжатия
+func (c *ZipCompressor) method() uint16 {
+	if c.Options.level() == flate.NoCompression {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+// writeZip записывает zip-архив source в поток w. Директории и симлинки сохраняются отдельными элементами
+func (c *ZipCompressor) writeZip(w io.Writer, source string) error {
+	writer := c.newZipWriter(w)
+
+	info, err := os.Stat(source)
 	if err != nil {
-		// Файл не существует или недоступен, пропускаем
-		return nil
+		return fmt.Errorf("failed to stat source: %w", err)
 	}
 
-	// Дополнительная проверка: если это директория, пропускаем
-	if info.IsDir() {
-		return nil
+	if !info.IsDir() {
+		if err := c.addEntryToZip(writer, source, filepath.Base(source), info); err != nil {
+			return err
+		}
+		return writer.Close()
 	}
 
-	// Пропускаем специальные файлы (socket, named pipe, device files)
-	mode := info.Mode()
-	if mode&os.ModeSocket != 0 || mode&os.ModeNamedPipe != 0 || mode&os.ModeDevice != 0 {
-		return nil
+	err = c.Options.walkSource(source, func(path, relPath string, info os.FileInfo) error {
+		return c.addEntryToZip(writer, path, relPath, info)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// addEntryToZip записывает элемент в zip. info получен через Lstat, поэтому симлинки не разыменовываются:
+// как и в Info-ZIP, симлинк хранится элементом с типом S_IFLNK в атрибутах и целью в качестве содержимого.
+// Права и тип записываются в Unix-атрибуты элемента, время модификации - в поле extended timestamp,
+// владелец - в поле Info-ZIP Unix ("ux")
+func (c *ZipCompressor) addEntryToZip(writer *zip.Writer, filePath, zipPath string, info os.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
 	}
 
-	// Если это симлинк, проверяем, что цель существует и это файл
-	if mode&os.ModeSymlink != 0 {
+	header.Name = filepath.ToSlash(zipPath)
+	header.Method = c.method()
+	header.Extra = append(header.Extra, zipOwnerExtra(info)...)
+
+	switch {
+	case info.IsDir():
+		header.Name += "/"
+		header.Method = zip.Store
+		_, err := writer.CreateHeader(header)
+		return err
+
+	case info.Mode()&os.ModeSymlink != 0:
 		target, err := os.Readlink(filePath)
 		if err != nil {
-			// Не удалось прочитать симлинк, пропускаем
+			SkipUnreadable(c.Options.Manifest, zipPath, false, err)
 			return nil
 		}
-		// Получаем абсолютный путь цели
-		if !filepath.IsAbs(target) {
-			target = filepath.Join(filepath.Dir(filePath), target)
-		}
-		// Проверяем, что цель существует и это файл
-		// Если цель не существует, просто добавляем симлинк как есть (без разыменования)
-		targetInfo, err := os.Stat(target)
+
+		header.Method = zip.Store
+		w, err := writer.CreateHeader(header)
 		if err != nil {
-			// Цель не существует, добавляем симлинк как есть
-			// Не меняем info, используем информацию о самом симлинке
-		} else if targetInfo.IsDir() {
-			// Цель - директория, пропускаем
-			return nil
-		} else {
-			// Используем информацию о цели для создания заголовка
-			info = targetInfo
+			return err
 		}
+		_, err = io.WriteString(w, target)
+		return err
+
+	case !info.Mode().IsRegular():
+		return nil
 	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		// Если не удалось открыть файл, пропускаем
+		SkipUnreadable(c.Options.Manifest, zipPath, false, err)
 		return nil
 	}
 	defer file.Close()
 
-	header, err := zip.FileInfoHeader(info)
-	if err != nil {
-		return err
-	}
+	reader, recorder := c.Options.newFileRecorder(file)
 
-	header.Name = zipPath
-	header.Method = zip.Deflate
+	// Уже сжатые файлы (изображения, видео, архивы) сохраняются как есть
+	if header.Method != zip.Store {
+		var store bool
+		if reader, store = c.Options.storeReader(filePath, reader); store {
+			header.Method = zip.Store
+			c.Options.countStored(info.Size())
+		}
+	}
 
 	w, err := writer.CreateHeader(header)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(w, file)
-	return err
+	if _, err := io.Copy(w, reader); err != nil {
+		return err
+	}
+
+	recorder.record(zipPath, info)
+	return nil
 }
 
-type TarCompressor struct{}
+type TarCompressor struct {
+	Options Options
+}
 
 func (c *TarCompressor) Compress(source, destination string) error {
-	tarFile, err := os.Create(destination)
+	tarFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create tar file: %w", err)
 	}
-	defer tarFile.Close()
+	defer tarFile.Abort()
+
+	if err := c.writeTar(tarFile, source); err != nil {
+		return err
+	}
+
+	return tarFile.Close()
+}
 
-	writer := tar.NewWriter(tarFile)
-	defer writer.Close()
+// writeTar записывает tar-архив source в поток w. Директории, симлинки и жесткие ссылки
+// сохраняются как отдельные элементы вместе с владельцем и расширенными атрибутами
+func (c *TarCompressor) writeTar(w io.Writer, source string) error {
+	writer := tar.NewWriter(w)
 
 	info, err := os.Stat(source)
 	if err != nil {
@@ -208,89 +264,160 @@ func (c *TarCompressor) Compress(source, destination string) error {
 	}
 
 	if !info.IsDir() {
-		return c.addFileToTar(writer, source, filepath.Base(source))
-	}
-
-	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
+		if err := c.addEntryToTar(writer, w, source, filepath.Base(source), info, nil); err != nil {
 			return err
 		}
+		return writer.Close()
+	}
 
-		if info.IsDir() {
+	// Пути уже записанных файлов с несколькими жесткими ссылками
+	links := make(map[FileID]manifest.File)
+
+	err = c.Options.walkSource(source, func(path, relPath string, info os.FileInfo) error {
+		return c.addEntryToTar(writer, w, path, relPath, info, links)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// addEntryToTar записывает элемент в tar. info получен через Lstat, поэтому симлинки не разыменовываются.
+// links хранит первые пути файлов с несколькими жесткими ссылками; nil отключает поиск жестких ссылок
+func (c *TarCompressor) addEntryToTar(writer *tar.Writer, w io.Writer, filePath, tarPath string, info os.FileInfo, links map[FileID]manifest.File) error {
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(filePath)
+		if err != nil {
+			SkipUnreadable(c.Options.Manifest, tarPath, false, err)
 			return nil
 		}
+		link = target
+	}
 
-		relPath, err := filepath.Rel(source, path)
-		if err != nil {
-			return err
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+
+	header.Name = filepath.ToSlash(tarPath)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	// Время доступа и изменения inode не восстанавливаются, не храним их;
+	// время модификации отбрасываем до секунд, как GNU tar, а не округляем
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.ModTime = header.ModTime.Truncate(time.Second)
+
+	if info.IsDir() || info.Mode().IsRegular() {
+		// Расширенные атрибуты (в том числе POSIX ACL) сохраняются записями PAX
+		if xattrs, err := readXattrs(filePath); err == nil && len(xattrs) > 0 {
+			header.PAXRecords = make(map[string]string, len(xattrs))
+			for name, value := range xattrs {
+				header.PAXRecords[paxXattrPrefix+name] = value
+			}
+			header.Format = tar.FormatPAX
 		}
+	}
 
-		return c.addFileToTar(writer, path, relPath)
-	})
-}
+	if !info.Mode().IsRegular() {
+		// Директории и симлинки записываются только заголовком
+		return writer.WriteHeader(header)
+	}
+
+	// Повторная жесткая ссылка записывается ссылкой на первый путь без содержимого
+	id, hardlink := HardlinkID(info)
+	hardlink = hardlink && links != nil
+	if hardlink {
+		if first, seen := links[id]; seen {
+			header.Typeflag = tar.TypeLink
+			header.Linkname = first.Path
+			header.Size = 0
+
+			if c.Options.Manifest != nil {
+				first.Path = header.Name
+				c.Options.Manifest.AddFile(first)
+			}
+			return writer.WriteHeader(header)
+		}
+	}
 
-func (c *TarCompressor) addFileToTar(writer *tar.Writer, filePath, tarPath string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return err
+		SkipUnreadable(c.Options.Manifest, tarPath, false, err)
+		return nil
 	}
 	defer file.Close()
 
-	info, err := file.Stat()
+	reader, recorder := c.Options.newFileRecorder(file)
+
+	if segments, ok := sparseSegments(file, info); ok {
+		err = writeSparseFile(writer, w, header, file, reader, segments, recorder)
+	} else if err = writer.WriteHeader(header); err == nil {
+		err = c.copyContent(writer, w, reader, filePath, header)
+	}
 	if err != nil {
 		return err
 	}
 
-	header, err := tar.FileInfoHeader(info, "")
-	if err != nil {
-		return err
+	recorded := recorder.record(tarPath, info)
+	if hardlink {
+		recorded.Path = header.Name
+		links[id] = recorded
 	}
 
-	header.Name = tarPath
+	return nil
+}
 
-	if err := writer.WriteHeader(header); err != nil {
-		return err
+// copyContent записывает содержимое файла после его заголовка. Если tar сжимается gzip
+// и включен Store.TarGz, уже сжатые файлы записываются без сжатия
+func (c *TarCompressor) copyContent(writer *tar.Writer, w io.Writer, reader io.Reader, filePath string, header *tar.Header) error {
+	if switcher, ok := w.(levelSwitcher); ok && c.Options.Store.TarGz && c.Options.level() != flate.NoCompression {
+		var store bool
+		if reader, store = c.Options.storeReader(filePath, reader); store {
+			c.Options.countStored(header.Size)
+			switcher.setLevel(flate.NoCompression)
+			defer switcher.setLevel(c.Options.level())
+		}
 	}
 
-	_, err = io.Copy(writer, file)
+	// Файл мог измениться после обхода, записываем ровно столько, сколько указано в заголовке
+	_, err := io.CopyN(writer, reader, header.Size)
+	if err == io.EOF {
+		return fmt.Errorf("file %s changed while archiving", header.Name)
+	}
 	return err
 }
 
-type TarGzCompressor struct{}
+type TarGzCompressor struct {
+	Options Options
+}
 
 func (c *TarGzCompressor) Compress(source, destination string) error {
-	// Сначала создаем tar во временный файл
-	tmpTar := destination + ".tmp.tar"
-	if err := (&TarCompressor{}).Compress(source, tmpTar); err != nil {
-		return err
-	}
-	defer os.Remove(tmpTar)
-
-	// Затем сжимаем gzip
-	tarFile, err := os.Open(tmpTar)
-	if err != nil {
-		return fmt.Errorf("failed to open tar file: %w", err)
-	}
-	defer tarFile.Close()
-
-	gzFile, err := os.Create(destination)
+	gzFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create gzip file: %w", err)
 	}
-	defer gzFile.Close()
+	defer gzFile.Abort()
 
-	writer := gzip.NewWriter(gzFile)
-	defer writer.Close()
+	// tar пишется сразу в gzip-поток без промежуточного файла
+	writer := newGzipWriter(gzFile, c.Options, "", time.Time{}, c.Options.Store.TarGz)
+	if err := (&TarCompressor{Options: c.Options}).writeTar(writer, source); err != nil {
+		return err
+	}
 
-	_, err = io.Copy(writer, tarFile)
-	if err != nil {
+	if err := writer.Close(); err != nil {
 		return fmt.Errorf("failed to compress tar: %w", err)
 	}
 
-	return nil
+	return gzFile.Close()
 }
 
-type NoCompressor struct{}
+type NoCompressor struct {
+	Options Options
+}
 
 func (c *NoCompressor) Compress(source, destination string) error {
 	srcFile, err := os.Open(source)
@@ -299,32 +426,54 @@ func (c *NoCompressor) Compress(source, destination string) error {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(destination)
+	dstFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer dstFile.Close()
+	defer dstFile.Abort()
 
 	_, err = io.Copy(dstFile, srcFile)
 	if err != nil {
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
 
-	return nil
+	return dstFile.Close()
 }
 
-func NewCompressor(compressionType string) (Compressor, error) {
+func (c *NoCompressor) CompressStream(r io.Reader, name, destination string) error {
+	dstFile, err := createArchive(destination, c.Options)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer dstFile.Abort()
+
+	if _, err := io.Copy(dstFile, r); err != nil {
+		return fmt.Errorf("failed to copy stream: %w", err)
+	}
+
+	return dstFile.Close()
+}
+
+// CompressionFilter - тип сжатия внешней программой (см. Filter)
+const CompressionFilter = "filter"
+
+func NewCompressor(compressionType string, opts Options) (Compressor, error) {
 	switch strings.ToLower(compressionType) {
 	case "gzip":
-		return &GzipCompressor{}, nil
+		return &GzipCompressor{Options: opts}, nil
 	case "zip":
-		return &ZipCompressor{}, nil
+		return &ZipCompressor{Options: opts}, nil
 	case "tar":
-		return &TarCompressor{}, nil
+		return &TarCompressor{Options: opts}, nil
 	case "tar.gz":
-		return &TarGzCompressor{}, nil
+		return &TarGzCompressor{Options: opts}, nil
 	case "none", "":
-		return &NoCompressor{}, nil
+		return &NoCompressor{Options: opts}, nil
+	case CompressionFilter:
+		if opts.Filter == nil {
+			return nil, fmt.Errorf("filter compression requires a command")
+		}
+		return &FilterCompressor{Filter: *opts.Filter, Options: opts}, nil
 	default:
 		return nil, fmt.Errorf("unsupported compression type: %s", compressionType)
 	}
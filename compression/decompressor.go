@@ -0,0 +1,430 @@
+package compression
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/encryption"
+	"goback/storage"
+)
+
+// Entry описывает элемент архива
+type Entry struct {
+	Name    string
+	Mode    os.FileMode
+	Size    int64
+	ModTime time.Time
+	// Linkname - цель симлинка или, для жесткой ссылки, путь в архиве файла с содержимым
+	Linkname string
+	// Hardlink - элемент является жесткой ссылкой на Linkname; его Mode содержит os.ModeIrregular
+	Hardlink bool
+	// Owner - владелец файла, nil если формат его не хранит
+	Owner *Owner
+	// Xattrs - расширенные атрибуты файла (включая POSIX ACL)
+	Xattrs map[string]string
+	// Sparse - файл был разреженным, при распаковке нулевые участки не записываются на диск
+	Sparse bool
+}
+
+// Owner - владелец элемента архива; при распаковке имена имеют приоритет над числовыми id
+type Owner struct {
+	Uid   int
+	Gid   int
+	Uname string
+	Gname string
+}
+
+// WalkFunc вызывается для каждого элемента архива, r читает содержимое файла
+type WalkFunc func(entry Entry, r io.Reader) error
+
+type Decompressor interface {
+	// Decompress распаковывает архив source в директорию destination
+	Decompress(source, destination string) error
+	// Walk последовательно передает элементы архива в fn, не записывая их на диск.
+	// Непрочитанное содержимое элемента пропускается
+	Walk(source string, fn WalkFunc) error
+}
+
+// archiveWalker обходит элементы уже открытого архива. name - имя файла архива
+// без номера тома и расширения шифрования
+type archiveWalker interface {
+	walkOpened(file archiveFile, name string, fn WalkFunc) error
+}
+
+// walkPath открывает архив source в хранилище b (в том числе разбитый на тома) и обходит его элементы
+func walkPath(w archiveWalker, b storage.Backend, source string, fn WalkFunc) error {
+	file, err := openArchive(b, source)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	return w.walkOpened(file, filepath.Base(source), fn)
+}
+
+// encryptedDecompressor расшифровывает архив .enc и передает его распаковщику формата архива
+type encryptedDecompressor struct {
+	inner      archiveWalker
+	identities []encryption.Identity
+	storage    storage.Backend
+}
+
+func (d *encryptedDecompressor) Decompress(source, destination string) error {
+	return extract(d, source, destination)
+}
+
+func (d *encryptedDecompressor) Walk(source string, fn WalkFunc) error {
+	file, err := openArchive(d.storage, source)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	decrypted, err := decryptArchive(file, d.identities)
+	if err != nil {
+		return err
+	}
+
+	return d.inner.walkOpened(decrypted, strings.TrimSuffix(filepath.Base(source), encryption.Extension), fn)
+}
+
+type GzipDecompressor struct {
+	Storage storage.Backend
+}
+
+func (d *GzipDecompressor) Decompress(source, destination string) error {
+	return extract(d, source, destination)
+}
+
+func (d *GzipDecompressor) Walk(source string, fn WalkFunc) error {
+	return walkPath(d, d.Storage, source, fn)
+}
+
+func (d *GzipDecompressor) walkOpened(file archiveFile, name string, fn WalkFunc) error {
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	defer reader.Close()
+
+	// Имя исходного файла хранится в заголовке gzip, иначе берем имя архива без .gz
+	if reader.Name != "" {
+		name = filepath.Base(reader.Name)
+	} else {
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	modTime := reader.ModTime
+	if modTime.IsZero() {
+		if info, err := file.Stat(); err == nil {
+			modTime = info.ModTime()
+		}
+	}
+
+	if err := fn(Entry{Name: name, Mode: 0644, Size: -1, ModTime: modTime}, reader); err != nil {
+		return err
+	}
+
+	// Дочитываем поток до конца, чтобы gzip проверил CRC
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+
+	return nil
+}
+
+type ZipDecompressor struct {
+	Storage storage.Backend
+}
+
+func (d *ZipDecompressor) Decompress(source, destination string) error {
+	return extract(d, source, destination)
+}
+
+func (d *ZipDecompressor) Walk(source string, fn WalkFunc) error {
+	return walkPath(d, d.Storage, source, fn)
+}
+
+func (d *ZipDecompressor) walkOpened(file archiveFile, name string, fn WalkFunc) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat zip file: %w", err)
+	}
+
+	reader, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return fmt.Errorf("failed to open zip file: %w", err)
+	}
+
+	for _, f := range reader.File {
+		if err := d.walkFile(f, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *ZipDecompressor) walkFile(f *zip.File, fn WalkFunc) error {
+	entry := Entry{
+		Name:    f.Name,
+		Mode:    f.Mode(),
+		Size:    int64(f.UncompressedSize64),
+		ModTime: f.Modified,
+		Owner:   zipOwner(f.Extra),
+	}
+
+	if entry.Mode.IsDir() {
+		return fn(entry, strings.NewReader(""))
+	}
+
+	// Содержимое элемента-симлинка - путь, на который он указывает
+	if entry.Mode&os.ModeSymlink != 0 {
+		target, err := readZipLink(f)
+		if err != nil {
+			return err
+		}
+		entry.Linkname = target
+		entry.Size = 0
+		return fn(entry, strings.NewReader(""))
+	}
+
+	// Элемент открывается только при чтении, поэтому пропущенные элементы не распаковываются
+	reader := &zipEntryReader{file: f}
+	defer reader.Close()
+
+	if err := fn(entry, reader); err != nil {
+		return err
+	}
+
+	// Дочитываем прочитанный элемент до конца, чтобы zip проверил CRC32
+	if reader.rc != nil {
+		if _, err := io.Copy(io.Discard, reader.rc); err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// readZipLink читает цель симлинка из содержимого элемента zip
+func readZipLink(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	target, err := io.ReadAll(io.LimitReader(rc, maxLinkTarget+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if len(target) > maxLinkTarget {
+		return "", fmt.Errorf("symlink target of %s is too long", f.Name)
+	}
+	return string(target), nil
+}
+
+// zipEntryReader лениво открывает элемент zip-архива при первом чтении
+type zipEntryReader struct {
+	file *zip.File
+	rc   io.ReadCloser
+}
+
+func (r *zipEntryReader) Read(p []byte) (int, error) {
+	if r.rc == nil {
+		rc, err := r.file.Open()
+		if err != nil {
+			return 0, fmt.Errorf("failed to open %s: %w", r.file.Name, err)
+		}
+		r.rc = rc
+	}
+	return r.rc.Read(p)
+}
+
+func (r *zipEntryReader) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+	return r.rc.Close()
+}
+
+type TarDecompressor struct {
+	Storage storage.Backend
+}
+
+func (d *TarDecompressor) Decompress(source, destination string) error {
+	return extract(d, source, destination)
+}
+
+func (d *TarDecompressor) Walk(source string, fn WalkFunc) error {
+	return walkPath(d, d.Storage, source, fn)
+}
+
+func (d *TarDecompressor) walkOpened(file archiveFile, name string, fn WalkFunc) error {
+	return walkTar(file, fn)
+}
+
+func walkTar(r io.Reader, fn WalkFunc) error {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		entry := Entry{
+			Name:    header.Name,
+			Mode:    header.FileInfo().Mode(),
+			Size:    header.Size,
+			ModTime: header.ModTime,
+			Owner: &Owner{
+				Uid:   header.Uid,
+				Gid:   header.Gid,
+				Uname: header.Uname,
+				Gname: header.Gname,
+			},
+			Xattrs: tarXattrs(header),
+		}
+
+		// Поддерживаем обычные (в том числе разреженные) файлы, директории, симлинки и жесткие ссылки
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeDir:
+		case tar.TypeGNUSparse:
+			entry.Sparse = true
+		case tar.TypeSymlink:
+			entry.Linkname = header.Linkname
+		case tar.TypeLink:
+			entry.Linkname = header.Linkname
+			entry.Hardlink = true
+			entry.Mode = entry.Mode.Perm() | os.ModeIrregular
+			entry.Size = 0
+		default:
+			continue
+		}
+
+		// Разреженные файлы в формате PAX отмечены записями GNU.sparse.*
+		for key := range header.PAXRecords {
+			if strings.HasPrefix(key, "GNU.sparse.") {
+				entry.Sparse = true
+				break
+			}
+		}
+
+		if err := fn(entry, reader); err != nil {
+			return err
+		}
+	}
+}
+
+// tarXattrs возвращает расширенные атрибуты из записей SCHILY.xattr.* заголовка PAX
+func tarXattrs(header *tar.Header) map[string]string {
+	var xattrs map[string]string
+	for key, value := range header.PAXRecords {
+		if name, ok := strings.CutPrefix(key, paxXattrPrefix); ok {
+			if xattrs == nil {
+				xattrs = make(map[string]string)
+			}
+			xattrs[name] = value
+		}
+	}
+	return xattrs
+}
+
+type TarGzDecompressor struct {
+	Storage storage.Backend
+}
+
+func (d *TarGzDecompressor) Decompress(source, destination string) error {
+	return extract(d, source, destination)
+}
+
+func (d *TarGzDecompressor) Walk(source string, fn WalkFunc) error {
+	return walkPath(d, d.Storage, source, fn)
+}
+
+func (d *TarGzDecompressor) walkOpened(file archiveFile, name string, fn WalkFunc) error {
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	defer reader.Close()
+
+	if err := walkTar(reader, fn); err != nil {
+		return err
+	}
+
+	// Дочитываем хвост gzip после конца tar, чтобы проверить CRC
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+
+	return nil
+}
+
+type NoDecompressor struct {
+	Storage storage.Backend
+}
+
+func (d *NoDecompressor) Decompress(source, destination string) error {
+	return extract(d, source, destination)
+}
+
+func (d *NoDecompressor) Walk(source string, fn WalkFunc) error {
+	return walkPath(d, d.Storage, source, fn)
+}
+
+func (d *NoDecompressor) walkOpened(file archiveFile, name string, fn WalkFunc) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	return fn(Entry{
+		Name:    name,
+		Mode:    info.Mode().Perm(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, file)
+}
+
+func NewDecompressor(compressionType string, b storage.Backend) (Decompressor, error) {
+	switch strings.ToLower(compressionType) {
+	case "gzip":
+		return &GzipDecompressor{Storage: b}, nil
+	case "zip":
+		return &ZipDecompressor{Storage: b}, nil
+	case "tar":
+		return &TarDecompressor{Storage: b}, nil
+	case "tar.gz":
+		return &TarGzDecompressor{Storage: b}, nil
+	case "none", "":
+		return &NoDecompressor{Storage: b}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression type: %s", compressionType)
+	}
+}
+
+// Verify читает архив целиком без записи на диск, проверяя контрольные суммы формата,
+// и возвращает количество прочитанных элементов
+func Verify(d Decompressor, source string) (int, error) {
+	count := 0
+	err := d.Walk(source, func(entry Entry, r io.Reader) error {
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name, err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
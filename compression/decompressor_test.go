@@ -0,0 +1,83 @@
+package compression
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goback/storage"
+)
+
+func TestVerifyCorrupted(t *testing.T) {
+	files := map[string]string{
+		"index.html":   string(testData(32 << 10)),
+		"css/site.css": string(testData(16 << 10)),
+	}
+	source := t.TempDir()
+	if err := writeTestFiles(source, files); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		compression string
+		source      string
+		name        string
+		entries     int
+		// Формат проверяет контрольную сумму содержимого; в tar ее нет, измененный байт
+		// в содержимом файла обнаруживает только файл контрольной суммы рядом с архивом
+		checksummed bool
+	}{
+		{compression: "gzip", source: filepath.Join(source, "index.html"), name: "index.html.gz", entries: 1, checksummed: true},
+		{compression: "zip", source: source, name: "site.zip", entries: 3, checksummed: true},
+		{compression: "tar", source: source, name: "site.tar", entries: 3},
+		{compression: "tar.gz", source: source, name: "site.tar.gz", entries: 3, checksummed: true},
+	}
+
+	// Повреждения: обрезанный архив и измененный байт в середине
+	corruptions := map[string]func([]byte) []byte{
+		"truncated": func(data []byte) []byte { return data[:len(data)/2] },
+		"flipped byte": func(data []byte) []byte {
+			data[len(data)/2] ^= 0xff
+			return data
+		},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		b := storage.NewLocal(dir)
+
+		c, err := NewCompressor(tt.compression, Options{Storage: b})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Compress(tt.source, tt.name); err != nil {
+			t.Fatalf("%s: compress: %v", tt.compression, err)
+		}
+		d, err := NewDecompressorFor(tt.name, ReadOptions{Storage: b})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if count, err := Verify(d, tt.name); err != nil || count != tt.entries {
+			t.Fatalf("%s: Verify of intact archive: %d entries, %v", tt.compression, count, err)
+		}
+
+		path := filepath.Join(dir, tt.name)
+		intact, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, corrupt := range corruptions {
+			if name == "flipped byte" && !tt.checksummed {
+				continue
+			}
+			data := corrupt(append([]byte(nil), intact...))
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Verify(d, tt.name); err == nil {
+				t.Errorf("%s: %s archive passed Verify", tt.compression, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package compression
+
+import (
+	"path/filepath"
+	"testing"
+
+	"goback/encryption"
+	"goback/storage"
+	"goback/utils"
+)
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	identity, err := encryption.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := encryption.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{"a.txt": string(testData(200000)), "b/c.txt": "small"}
+	source := t.TempDir()
+	if err := writeTestFiles(source, files); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compressionType := range []string{"gzip", "zip", "tar", "tar.gz"} {
+		name := "site" + utils.GetExtension(compressionType) + encryption.Extension
+		opts := Options{Encryption: []encryption.Recipient{identity.Recipient()}, SplitSize: 64 << 10}
+		read := ReadOptions{Identities: []encryption.Identity{other, identity}}
+
+		src := source
+		check := files
+		if compressionType == "gzip" {
+			// gzip сжимает один файл
+			src = filepath.Join(source, "a.txt")
+			check = map[string]string{"a.txt": files["a.txt"]}
+		}
+
+		b, destination := roundTrip(t, compressionType, opts, read, src, name)
+		checkTestFiles(t, destination, check)
+
+		d, err := NewDecompressorFor(name, ReadOptions{Identities: []encryption.Identity{other}, Storage: b})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Decompress(name, t.TempDir()); err == nil {
+			t.Errorf("%s: archive decrypted with a key it was not encrypted for", compressionType)
+		}
+
+		if _, err := NewDecompressorFor(name, ReadOptions{Storage: storage.NewLocal(t.TempDir())}); err == nil {
+			t.Errorf("%s: encrypted archive opened without keys", compressionType)
+		}
+	}
+}
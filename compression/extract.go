@@ -0,0 +1,465 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"goback/utils"
+)
+
+// extract распаковывает архив в destination, восстанавливая права и время модификации
+func extract(d Decompressor, source, destination string) error {
+	_, err := extractMatching(d, source, destination, nil)
+	return err
+}
+
+// DecompressPaths распаковывает только элементы, совпадающие с одним из шаблонов (см. utils.MatchPath),
+// и возвращает количество распакованных элементов
+func DecompressPaths(d Decompressor, source, destination string, patterns []string) (int, error) {
+	return extractMatching(d, source, destination, func(name string) bool {
+		return matchAny(patterns, name)
+	})
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if utils.MatchPath(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecompressFile записывает в w содержимое первого файла архива, совпадающего с одним из шаблонов
+// (первого файла архива, если шаблоны не заданы), и возвращает его описание
+func DecompressFile(d Decompressor, source string, patterns []string, w io.Writer) (Entry, error) {
+	var found *Entry
+	err := d.Walk(source, func(entry Entry, r io.Reader) error {
+		if found != nil || !entry.Mode.IsRegular() {
+			return nil
+		}
+
+		if len(patterns) > 0 && !matchAny(patterns, entry.Name) {
+			return nil
+		}
+
+		found = &entry
+		if _, err := io.Copy(w, r); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", entry.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if found == nil {
+		return Entry{}, fmt.Errorf("no matching file found in archive")
+	}
+
+	return *found, nil
+}
+
+// extractMatching распаковывает элементы, для которых match возвращает true (все, если match == nil)
+func extractMatching(d Decompressor, source, destination string, match func(name string) bool) (int, error) {
+	absDestination, err := filepath.Abs(destination)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get absolute path for destination: %w", err)
+	}
+
+	if err := os.MkdirAll(absDestination, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	// Права и время модификации директорий выставляем в конце,
+	// так как создание файлов внутри меняет время, а права могут запрещать запись
+	type dirAttrs struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs []dirAttrs
+	owners := newOwnerLookup()
+	count := 0
+
+	// Распакованные обычные файлы и жесткие ссылки, чей файл с содержимым не распакован
+	// (не совпал с шаблонами), по пути файла с содержимым
+	extracted := make(map[string]bool)
+	pending := make(map[string][]string)
+
+	err = d.Walk(source, func(entry Entry, r io.Reader) error {
+		if match != nil && !match(entry.Name) {
+			return nil
+		}
+
+		target, err := safeJoin(absDestination, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		if entry.Mode.IsDir() {
+			// Симлинк на месте директории удаляется, иначе права, время и владелец
+			// выставлялись бы его цели за пределами destination
+			if err := removeExisting(target); err != nil {
+				return fmt.Errorf("failed to replace %s: %w", entry.Name, err)
+			}
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", entry.Name, err)
+			}
+			if err := os.Chmod(target, entry.Mode.Perm()|0700); err != nil {
+				return fmt.Errorf("failed to chmod %s: %w", entry.Name, err)
+			}
+			if err := restoreOwnership(target, entry, owners); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirAttrs{path: target, mode: fileMode(entry.Mode), modTime: entry.ModTime})
+			count++
+			return nil
+		}
+
+		if !entry.Hardlink && !entry.Mode.IsRegular() && entry.Mode&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", entry.Name, err)
+		}
+
+		if err := removeExisting(target); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", entry.Name, err)
+		}
+
+		switch {
+		case entry.Hardlink:
+			// Содержимое и атрибуты общие с уже распакованным файлом
+			linkTarget, err := safeJoin(absDestination, entry.Linkname)
+			if err != nil {
+				return err
+			}
+			if !extracted[linkTarget] {
+				pending[linkTarget] = append(pending[linkTarget], target)
+				return nil
+			}
+			if err := os.Link(linkTarget, target); err != nil {
+				return fmt.Errorf("failed to link %s to %s: %w", entry.Name, entry.Linkname, err)
+			}
+
+		case entry.Mode&os.ModeSymlink != 0:
+			if err := os.Symlink(entry.Linkname, target); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", entry.Name, err)
+			}
+			if err := restoreOwnership(target, entry, owners); err != nil {
+				return err
+			}
+			if !entry.ModTime.IsZero() {
+				if err := lchtimes(target, entry.ModTime); err != nil {
+					return fmt.Errorf("failed to set modification time for %s: %w", entry.Name, err)
+				}
+			}
+
+		default:
+			if err := extractFile(target, entry, r, owners); err != nil {
+				return err
+			}
+			extracted[target] = true
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	if len(pending) > 0 {
+		linked, err := extractPendingLinks(d, source, absDestination, pending, owners)
+		count += linked
+		if err != nil {
+			return count, err
+		}
+	}
+
+	// Идем в обратном порядке, чтобы вложенные директории обрабатывались раньше родительских
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := checkDirectory(dirs[i].path); err != nil {
+			return count, err
+		}
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return count, fmt.Errorf("failed to chmod %s: %w", dirs[i].path, err)
+		}
+		if dirs[i].modTime.IsZero() {
+			continue
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime); err != nil {
+			return count, fmt.Errorf("failed to set modification time for %s: %w", dirs[i].path, err)
+		}
+	}
+
+	return count, nil
+}
+
+// extractFile записывает обычный файл и восстанавливает его владельца, права и время модификации
+func extractFile(target string, entry Entry, r io.Reader, owners *ownerLookup) error {
+	if err := writeFile(target, r, entry.Mode.Perm(), entry.Sparse); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", entry.Name, err)
+	}
+	if err := restoreOwnership(target, entry, owners); err != nil {
+		return err
+	}
+	// Права выставляются после смены владельца, которая сбрасывает setuid/setgid
+	if err := os.Chmod(target, fileMode(entry.Mode)); err != nil {
+		return fmt.Errorf("failed to chmod %s: %w", entry.Name, err)
+	}
+	if !entry.ModTime.IsZero() {
+		if err := os.Chtimes(target, entry.ModTime, entry.ModTime); err != nil {
+			return fmt.Errorf("failed to set modification time for %s: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// extractPendingLinks распаковывает жесткие ссылки, файл с содержимым которых не совпал с шаблонами.
+// Архив читается повторно: содержимое записывается в первую ссылку, остальные ссылаются на нее
+func extractPendingLinks(d Decompressor, source, root string, pending map[string][]string, owners *ownerLookup) (int, error) {
+	count := 0
+	err := d.Walk(source, func(entry Entry, r io.Reader) error {
+		if !entry.Mode.IsRegular() {
+			return nil
+		}
+
+		path, err := safeJoin(root, entry.Name)
+		if err != nil {
+			return err
+		}
+		links, ok := pending[path]
+		if !ok {
+			return nil
+		}
+		delete(pending, path)
+
+		// Родительские директории ссылок уже созданы при первом проходе
+		if err := extractFile(links[0], entry, r, owners); err != nil {
+			return err
+		}
+		count++
+
+		for _, link := range links[1:] {
+			if err := os.Link(links[0], link); err != nil {
+				return fmt.Errorf("failed to link %s to %s: %w", link, links[0], err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	for path := range pending {
+		name, _ := filepath.Rel(root, path)
+		return count, fmt.Errorf("failed to extract hard link: target %s not found in archive", filepath.ToSlash(name))
+	}
+
+	return count, nil
+}
+
+// fileMode возвращает права элемента вместе с битами setuid, setgid и sticky
+func fileMode(mode os.FileMode) os.FileMode {
+	return mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// restoreOwnership восстанавливает владельца (только при запуске от root) и расширенные атрибуты элемента
+func restoreOwnership(path string, entry Entry, owners *ownerLookup) error {
+	if entry.Owner != nil && os.Geteuid() == 0 {
+		uid, gid := owners.ids(entry.Owner)
+		if err := os.Lchown(path, uid, gid); err != nil {
+			return fmt.Errorf("failed to chown %s: %w", entry.Name, err)
+		}
+	}
+
+	// Для симлинков расширенные атрибуты не сохраняются
+	if entry.Mode&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	for name, value := range entry.Xattrs {
+		if err := writeXattr(path, name, value); err != nil {
+			return fmt.Errorf("failed to set extended attribute %s on %s: %w", name, entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ownerLookup находит uid и gid по именам владельцев из архива, запоминая результаты.
+// Если имени нет в системе, используется числовой id из архива
+type ownerLookup struct {
+	users  map[string]int
+	groups map[string]int
+}
+
+func newOwnerLookup() *ownerLookup {
+	return &ownerLookup{users: make(map[string]int), groups: make(map[string]int)}
+}
+
+func (l *ownerLookup) ids(owner *Owner) (int, int) {
+	uid := lookupID(l.users, owner.Uname, owner.Uid, func(name string) (string, error) {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		return u.Uid, nil
+	})
+	gid := lookupID(l.groups, owner.Gname, owner.Gid, func(name string) (string, error) {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+		return g.Gid, nil
+	})
+	return uid, gid
+}
+
+func lookupID(cache map[string]int, name string, fallback int, lookup func(name string) (string, error)) int {
+	if name == "" {
+		return fallback
+	}
+
+	if id, ok := cache[name]; ok {
+		return id
+	}
+
+	id := fallback
+	if value, err := lookup(name); err == nil {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			id = parsed
+		}
+	}
+
+	cache[name] = id
+	return id
+}
+
+// safeJoin возвращает путь элемента архива внутри root,
+// запрещая выход за его пределы через "..", абсолютные пути и симлинки
+func safeJoin(root, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("unsafe path in archive: %s", name)
+	}
+
+	target := filepath.Join(root, cleaned)
+	if target == root {
+		return target, nil
+	}
+
+	// Проверяем, что ни один из родительских элементов не является симлинком
+	current := root
+	parts := strings.Split(cleaned, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", current, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("unsafe path in archive: %s (parent %s is a symlink)", name, current)
+		}
+	}
+
+	return target, nil
+}
+
+// checkDirectory проверяет, что path - директория, а не подмененный на нее симлинк
+func checkDirectory(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("unsafe path in archive: %s is not a directory", path)
+	}
+	return nil
+}
+
+// removeExisting удаляет существующий файл или симлинк, чтобы не писать через него
+func removeExisting(path string) error {
+	if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// writeFile создает файл с содержимым из r. Для разреженных файлов нулевые блоки
+// не записываются, а пропускаются, так что на диске снова образуются дыры
+func writeFile(path string, r io.Reader, mode os.FileMode, sparse bool) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	if sparse {
+		err = copySparse(file, r)
+	} else {
+		_, err = io.Copy(file, r)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// sparseBlockSize - размер блока, который при распаковке разреженного файла проверяется на нули
+const sparseBlockSize = 4096
+
+func copySparse(file *os.File, r io.Reader) error {
+	buf := make([]byte, 32*sparseBlockSize)
+	var size int64
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		for start := 0; start < n; start += sparseBlockSize {
+			end := min(start+sparseBlockSize, n)
+			block := buf[start:end]
+
+			if isZero(block) {
+				if _, err := file.Seek(int64(len(block)), io.SeekCurrent); err != nil {
+					return err
+				}
+			} else if _, err := file.Write(block); err != nil {
+				return err
+			}
+		}
+		size += int64(n)
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Дыра в конце файла создается установкой размера
+	return file.Truncate(size)
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,311 @@
+package compression
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goback/storage"
+)
+
+func TestSafeJoin(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "a/b.txt", want: filepath.Join(root, "a", "b.txt")},
+		{name: "./a/../b.txt", want: filepath.Join(root, "b.txt")},
+		{name: ".", want: root},
+		// Сам симлинк допустим: распаковка заменяет его, а не пишет через него
+		{name: "link", want: filepath.Join(root, "link")},
+		{name: "..", wantErr: true},
+		{name: "../evil", wantErr: true},
+		{name: "a/../../evil", wantErr: true},
+		{name: "/etc/passwd", wantErr: true},
+		{name: "link/evil", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := safeJoin(root, tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("safeJoin(%q) = %q, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("safeJoin(%q) error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("safeJoin(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// writeTestTar создает в dir архив name из заголовков; содержимое обычных файлов - body
+func writeTestTar(t *testing.T, dir, name string, headers []*tar.Header, body string) {
+	t.Helper()
+
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := tar.NewWriter(file)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(body))
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := writer.Write([]byte(body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func extractTestTar(t *testing.T, dir, name, destination string) error {
+	t.Helper()
+
+	d, err := NewDecompressor("tar", storage.NewLocal(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.Decompress(name, destination)
+}
+
+// roundTrip сжимает source в архив name хранилища во временной директории и распаковывает его
+// с параметрами чтения read в новую директорию, которую возвращает
+func roundTrip(t *testing.T, compressionType string, opts Options, read ReadOptions, source, name string) (storage.Backend, string) {
+	t.Helper()
+
+	b := storage.NewLocal(t.TempDir())
+	opts.Storage = b
+
+	c, err := NewCompressor(compressionType, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Compress(source, name); err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+
+	read.Storage = b
+	d, err := NewDecompressorFor(name, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destination := t.TempDir()
+	if err := d.Decompress(name, destination); err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+	return b, destination
+}
+
+// writeTestFiles создает в dir файлы с указанным содержимым
+func writeTestFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTestFiles проверяет содержимое файлов, распакованных в dir
+func checkTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: content differs after round trip (%d bytes, want %d)", name, len(got), len(want))
+		}
+	}
+}
+
+// Регрессия: запись директории поверх симлинка меняла права и время цели симлинка за пределами destination
+func TestExtractDirectoryOverSymlink(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "outside")
+	if err := os.Mkdir(outside, 0700); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestTar(t, dir, "evil.tar", []*tar.Header{
+		{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+		{Name: "a/", Typeflag: tar.TypeDir, Mode: 0777, ModTime: time.Unix(0, 0)},
+	}, "")
+
+	destination := filepath.Join(dir, "restore")
+	if err := extractTestTar(t, dir, "evil.tar", destination); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+
+	after, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Mode() != before.Mode() || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("directory outside destination changed: mode %v -> %v, mtime %v -> %v",
+			before.Mode(), after.Mode(), before.ModTime(), after.ModTime())
+	}
+
+	info, err := os.Lstat(filepath.Join(destination, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Errorf("a is %v, want a directory", info.Mode())
+	}
+}
+
+func TestExtractFileOverSymlink(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "victim")
+	if err := os.WriteFile(outside, []byte("original"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestTar(t, dir, "evil.tar", []*tar.Header{
+		{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+		{Name: "a", Typeflag: tar.TypeReg, Mode: 0666},
+	}, "payload")
+
+	destination := filepath.Join(dir, "restore")
+	if err := extractTestTar(t, dir, "evil.tar", destination); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+
+	data, err := os.ReadFile(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "original" {
+		t.Errorf("file outside destination overwritten: %q", data)
+	}
+
+	data, err = os.ReadFile(filepath.Join(destination, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "payload" {
+		t.Errorf("restored a = %q, want %q", data, "payload")
+	}
+}
+
+func TestExtractThroughSymlinkParent(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+
+	writeTestTar(t, dir, "evil.tar", []*tar.Header{
+		{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+		{Name: "a/evil.txt", Typeflag: tar.TypeReg, Mode: 0644},
+	}, "payload")
+
+	if err := extractTestTar(t, dir, "evil.tar", filepath.Join(dir, "restore")); err == nil {
+		t.Fatal("expected error for path through symlink")
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("file written outside destination: %v", err)
+	}
+}
+
+func TestExtractTraversal(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestTar(t, dir, "evil.tar", []*tar.Header{
+		{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644},
+	}, "payload")
+
+	if err := extractTestTar(t, dir, "evil.tar", filepath.Join(dir, "restore")); err == nil {
+		t.Fatal("expected error for path outside destination")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("file written outside destination: %v", err)
+	}
+}
+
+func TestDecompressPathsHardlinkTargetFiltered(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeTestTar(t, dir, "links.tar", []*tar.Header{
+		{Name: "a/data", Typeflag: tar.TypeReg, Mode: 0640, ModTime: modTime},
+		{Name: "b/first", Typeflag: tar.TypeLink, Linkname: "a/data"},
+		{Name: "b/second", Typeflag: tar.TypeLink, Linkname: "a/data"},
+	}, "content")
+
+	d, err := NewDecompressor("tar", storage.NewLocal(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(dir, "restore")
+	count, err := DecompressPaths(d, "links.tar", destination, []string{"b"})
+	if err != nil {
+		t.Fatalf("DecompressPaths: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	if _, err := os.Lstat(filepath.Join(destination, "a", "data")); !os.IsNotExist(err) {
+		t.Errorf("filtered file a/data was extracted: %v", err)
+	}
+
+	first, err := os.Stat(filepath.Join(destination, "b", "first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.Stat(filepath.Join(destination, "b", "second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(first, second) {
+		t.Error("b/first and b/second are not hard links to the same file")
+	}
+	if first.Mode().Perm() != 0640 || !first.ModTime().Equal(modTime) {
+		t.Errorf("b/first mode %v, mtime %v; want 0640, %v", first.Mode().Perm(), first.ModTime(), modTime)
+	}
+
+	data, err := os.ReadFile(filepath.Join(destination, "b", "second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "content" {
+		t.Errorf("b/second = %q, want %q", data, "content")
+	}
+}
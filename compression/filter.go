@@ -0,0 +1,236 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"goback/encryption"
+	"goback/storage"
+	"goback/utils"
+)
+
+// Filter описывает внешнюю программу сжатия (zstd, xz, lz4, bzip2 и т.п.).
+// Директория передается программе в stdin как tar-поток, файл или stdout команды - как есть
+type Filter struct {
+	// Command сжимает данные из stdin в stdout
+	Command string
+	// Extension - расширение архива, например ".zst"; для директорий перед ним добавляется ".tar"
+	Extension string
+	// DecompressCommand распаковывает данные из stdin в stdout
+	DecompressCommand string
+}
+
+// ArchiveExtension возвращает расширение архива для директории (tar-поток) или для файла
+func (f *Filter) ArchiveExtension(directory bool) string {
+	if directory {
+		return ".tar" + f.Extension
+	}
+	return f.Extension
+}
+
+// Matches проверяет, создан ли архив этим фильтром
+func (f *Filter) Matches(filename string) bool {
+	return f.Extension != "" && strings.HasSuffix(filename, f.Extension)
+}
+
+// isTar проверяет, содержит ли архив фильтра tar-поток
+func (f *Filter) isTar(filename string) bool {
+	return strings.HasSuffix(filename, ".tar"+f.Extension)
+}
+
+type FilterCompressor struct {
+	Filter  Filter
+	Options Options
+}
+
+func (c *FilterCompressor) Compress(source, destination string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("failed to stat source: %w", err)
+	}
+
+	return c.run(destination, func(w io.Writer) error {
+		if info.IsDir() {
+			return (&TarCompressor{Options: c.Options}).writeTar(w, source)
+		}
+
+		srcFile, err := os.Open(source)
+		if err != nil {
+			return fmt.Errorf("failed to open source file: %w", err)
+		}
+		defer srcFile.Close()
+
+		_, err = io.Copy(w, srcFile)
+		return err
+	})
+}
+
+func (c *FilterCompressor) CompressStream(r io.Reader, name, destination string) error {
+	return c.run(destination, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+// run выполняет команду фильтра через shell, передавая в stdin данные, записанные write,
+// и сохраняя stdout в destination
+func (c *FilterCompressor) run(destination string, write func(w io.Writer) error) error {
+	command := strings.TrimSpace(c.Filter.Command)
+	if command == "" {
+		return fmt.Errorf("empty filter command")
+	}
+
+	dstFile, err := createArchive(destination, c.Options)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer dstFile.Abort()
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = dstFile
+	cmd.Stderr = utils.MaskWriter(os.Stderr)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start filter command: %w", err)
+	}
+
+	writeErr := write(stdin)
+	stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("filter command failed: %w", err)
+	}
+
+	if writeErr != nil {
+		return fmt.Errorf("failed to write to filter command: %w", writeErr)
+	}
+
+	return dstFile.Close()
+}
+
+type FilterDecompressor struct {
+	Filter  Filter
+	Storage storage.Backend
+}
+
+func (d *FilterDecompressor) Decompress(source, destination string) error {
+	return extract(d, source, destination)
+}
+
+func (d *FilterDecompressor) Walk(source string, fn WalkFunc) error {
+	return walkPath(d, d.Storage, source, fn)
+}
+
+func (d *FilterDecompressor) walkOpened(file archiveFile, name string, fn WalkFunc) error {
+	command := strings.TrimSpace(d.Filter.DecompressCommand)
+	if command == "" {
+		return fmt.Errorf("empty decompress command")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = file
+	cmd.Stderr = utils.MaskWriter(os.Stderr)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start decompress command: %w", err)
+	}
+
+	if d.Filter.isTar(name) {
+		err = walkTar(stdout, fn)
+	} else {
+		err = fn(Entry{
+			Name:    strings.TrimSuffix(name, d.Filter.Extension),
+			Mode:    0644,
+			Size:    -1,
+			ModTime: info.ModTime(),
+		}, stdout)
+	}
+
+	// Дочитываем вывод до конца, чтобы программа проверила весь поток
+	if err == nil {
+		if _, err = io.Copy(io.Discard, stdout); err != nil {
+			err = fmt.Errorf("failed to decompress: %w", err)
+		}
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("decompress command failed: %w", err)
+	}
+
+	return nil
+}
+
+// ReadOptions - параметры чтения архивов бэкапа
+type ReadOptions struct {
+	// Filter - внешняя программа сжатия бэкапа или nil
+	Filter *Filter
+	// Identities - ключи расшифровки архивов .enc
+	Identities []encryption.Identity
+	// Storage - хранилище архивов; source распаковщика - имя объекта в нем
+	Storage storage.Backend
+}
+
+// DetectCompression определяет тип сжатия архива с учетом фильтра бэкапа (может быть nil).
+// Расширение шифрования не учитывается
+func DetectCompression(filename string, filter *Filter) string {
+	filename = strings.TrimSuffix(filename, encryption.Extension)
+	if filter != nil && filter.Matches(filename) {
+		return CompressionFilter
+	}
+	return utils.DetectCompression(filename)
+}
+
+// IsEncrypted проверяет по имени, зашифрован ли архив
+func IsEncrypted(filename string) bool {
+	return strings.HasSuffix(filename, encryption.Extension)
+}
+
+// NewDecompressorFor возвращает распаковщик для архива filename. Архивы с расширением фильтра
+// распаковываются его командой, остальные - по типу, определенному по расширению.
+// Архивы .enc расшифровываются ключами из opts
+func NewDecompressorFor(filename string, opts ReadOptions) (Decompressor, error) {
+	if IsEncrypted(filename) {
+		if len(opts.Identities) == 0 {
+			return nil, fmt.Errorf("archive %s is encrypted, but no encryption key is configured for the backup (set identity_file or pass --identity)", filename)
+		}
+
+		inner, err := NewDecompressorFor(strings.TrimSuffix(filename, encryption.Extension), ReadOptions{Filter: opts.Filter, Storage: opts.Storage})
+		if err != nil {
+			return nil, err
+		}
+		walker, ok := inner.(archiveWalker)
+		if !ok {
+			return nil, fmt.Errorf("encrypted %s archives are not supported", filename)
+		}
+		return &encryptedDecompressor{inner: walker, identities: opts.Identities, storage: opts.Storage}, nil
+	}
+
+	if opts.Filter != nil && opts.Filter.Matches(filename) {
+		return &FilterDecompressor{Filter: *opts.Filter, Storage: opts.Storage}, nil
+	}
+	return NewDecompressor(utils.DetectCompression(filename), opts.Storage)
+}
@@ -0,0 +1,174 @@
+package compression
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goback/storage"
+	"goback/utils"
+)
+
+// gzipFilter - фильтр через внешнюю программу gzip
+var gzipFilter = &Filter{Command: "gzip -c", Extension: ".gz", DecompressCommand: "gzip -dc"}
+
+func TestFilterRoundTripDirectory(t *testing.T) {
+	files := map[string]string{
+		"index.html":     "<html>",
+		"css/site.css":   strings.Repeat("body { margin: 0 }\n", 100),
+		"data/empty.txt": "",
+	}
+	source := t.TempDir()
+	if err := writeTestFiles(source, files); err != nil {
+		t.Fatal(err)
+	}
+
+	b, destination := roundTrip(t, CompressionFilter, Options{Filter: gzipFilter}, ReadOptions{Filter: gzipFilter}, source, "site.tar.gz")
+	checkTestFiles(t, destination, files)
+
+	// Архив - настоящий gzip с tar-потоком внутри
+	file, err := b.Open("site.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[header.Name] = true
+	}
+	if !names["css/site.css"] {
+		t.Errorf("tar stream entries %v, want css/site.css", names)
+	}
+}
+
+func TestFilterRoundTripFile(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(source, []byte("CREATE TABLE t;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, destination := roundTrip(t, CompressionFilter, Options{Filter: gzipFilter}, ReadOptions{Filter: gzipFilter}, source, "dump.sql.gz")
+	checkTestFiles(t, destination, map[string]string{"dump.sql": "CREATE TABLE t;\n"})
+}
+
+func TestFilterCompressStream(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+	c := &FilterCompressor{Filter: *gzipFilter, Options: Options{Storage: b}}
+	if err := c.CompressStream(strings.NewReader("streamed dump"), "db", "db.gz"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := storage.ReadFile(b, "db.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil || string(got) != "streamed dump" {
+		t.Errorf("stream archive = %q, %v", got, err)
+	}
+}
+
+func TestFilterCommandFailure(t *testing.T) {
+	source := t.TempDir()
+	if err := writeTestFiles(source, map[string]string{"a.txt": strings.Repeat("data", 1000)}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	b := storage.NewLocal(dir)
+
+	// Программа сжатия пишет часть вывода и завершается с ошибкой
+	failing := &Filter{Command: "head -c 100; exit 3", Extension: ".gz", DecompressCommand: "gzip -dc"}
+	c := &FilterCompressor{Filter: *failing, Options: Options{Storage: b}}
+	if err := c.Compress(source, "site.tar.gz"); err == nil || !strings.Contains(err.Error(), "filter command failed") {
+		t.Fatalf("Compress error = %v, want filter command failure", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("partial archive left in storage: %v", entries)
+	}
+
+	// Ошибка программы распаковки тоже не скрывается
+	c = &FilterCompressor{Filter: *gzipFilter, Options: Options{Storage: b}}
+	if err := c.Compress(source, "site.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+	broken := &FilterDecompressor{Filter: Filter{Extension: ".gz", DecompressCommand: "cat >/dev/null; exit 2"}, Storage: b}
+	if err := broken.Decompress("site.tar.gz", t.TempDir()); err == nil {
+		t.Error("failing decompress command was not reported")
+	}
+}
+
+// captureStderr возвращает то, что fn вывела в os.Stderr
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	fn()
+	w.Close()
+	return <-output
+}
+
+func TestFilterMasksStderr(t *testing.T) {
+	utils.AddSecret("filter-secret-1234")
+
+	source := t.TempDir()
+	if err := writeTestFiles(source, map[string]string{"dump.sql": "data"}); err != nil {
+		t.Fatal(err)
+	}
+
+	filter := &Filter{
+		Command:           "echo compress filter-secret-1234 >&2; cat",
+		Extension:         ".cat",
+		DecompressCommand: "echo decompress filter-secret-1234 >&2; cat",
+	}
+
+	output := captureStderr(t, func() {
+		roundTrip(t, CompressionFilter, Options{Filter: filter}, ReadOptions{Filter: filter},
+			filepath.Join(source, "dump.sql"), "dump.sql.cat")
+	})
+
+	if strings.Contains(output, "filter-secret-1234") {
+		t.Errorf("secret leaked through filter stderr: %q", output)
+	}
+	if !strings.Contains(output, "compress ******") || !strings.Contains(output, "decompress ******") {
+		t.Errorf("filter stderr = %q, want masked messages of both commands", output)
+	}
+}
@@ -0,0 +1,37 @@
+package compression
+
+import (
+	"compress/flate"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Именованные уровни сжатия для compression_level
+var namedLevels = map[string]int{
+	"store":   flate.NoCompression,
+	"fastest": flate.BestSpeed,
+	"fast":    3,
+	"default": flate.DefaultCompression,
+	"best":    flate.BestCompression,
+}
+
+// ParseLevel преобразует значение compression_level (store, fastest, fast, default, best или 0-9)
+// в уровень deflate. Пустое значение означает уровень по умолчанию
+func ParseLevel(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return flate.DefaultCompression, nil
+	}
+
+	if level, ok := namedLevels[value]; ok {
+		return level, nil
+	}
+
+	level, err := strconv.Atoi(value)
+	if err != nil || level < flate.NoCompression || level > flate.BestCompression {
+		return 0, fmt.Errorf("invalid compression level %q (expected store, fastest, fast, default, best or 0-9)", value)
+	}
+
+	return level, nil
+}
@@ -0,0 +1,82 @@
+package compression
+
+import (
+	"compress/flate"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: flate.DefaultCompression},
+		{value: "store", want: flate.NoCompression},
+		{value: " Fastest ", want: flate.BestSpeed},
+		{value: "fast", want: 3},
+		{value: "default", want: flate.DefaultCompression},
+		{value: "best", want: flate.BestCompression},
+		{value: "0", want: 0},
+		{value: "9", want: 9},
+		{value: "10", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "max", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLevel(%q) = %d, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestCompressionLevels(t *testing.T) {
+	data := testData(512 << 10)
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "data.txt"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		compression string
+		source      string
+		name        string
+		threads     int
+	}{
+		{compression: "gzip", source: filepath.Join(source, "data.txt"), name: "data.txt.gz"},
+		{compression: "gzip", source: filepath.Join(source, "data.txt"), name: "data.txt.gz", threads: 4},
+		{compression: "zip", source: source, name: "site.zip"},
+		{compression: "tar.gz", source: source, name: "site.tar.gz"},
+		{compression: "tar.gz", source: source, name: "site.tar.gz", threads: 4},
+	}
+
+	for _, tt := range tests {
+		// Размер архива на каждом уровне; чем выше уровень, тем меньше архив
+		var sizes []int64
+		for _, level := range []string{"store", "fastest", "best"} {
+			opts := Options{Level: level, Threads: tt.threads}
+			b, destination := roundTrip(t, tt.compression, opts, ReadOptions{}, tt.source, tt.name)
+			checkTestFiles(t, destination, map[string]string{"data.txt": string(data)})
+
+			info, err := b.Stat(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sizes = append(sizes, info.Size())
+		}
+
+		if sizes[0] < int64(len(data)) || sizes[1] >= sizes[0] || sizes[2] >= sizes[1] {
+			t.Errorf("%s (%d threads): archive sizes for store, fastest, best = %v", tt.compression, tt.threads, sizes)
+		}
+	}
+}
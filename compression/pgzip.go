@@ -0,0 +1,279 @@
+package compression
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// parallelBlockSize - размер блока входных данных, который сжимается отдельной горутиной
+	parallelBlockSize = 1 << 20
+	// dictionarySize - размер окна deflate; конец предыдущего блока используется как словарь следующего
+	dictionarySize = 32 << 10
+)
+
+// levelSwitcher меняет уровень сжатия данных, записанных после вызова
+type levelSwitcher interface {
+	setLevel(level int)
+}
+
+// newGzipWriter возвращает gzip-писатель для потока w с уровнем сжатия из opts. При opts.Threads > 1
+// данные сжимаются параллельно независимыми блоками, результат остается обычным gzip-потоком.
+// switchable требует писатель с levelSwitcher, который есть только у блочного писателя
+func newGzipWriter(w io.Writer, opts Options, name string, modTime time.Time, switchable bool) io.WriteCloser {
+	level := opts.level()
+
+	if opts.Threads <= 1 && !switchable {
+		writer, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			writer = gzip.NewWriter(w)
+		}
+		writer.Name = name
+		writer.ModTime = modTime
+		return writer
+	}
+
+	threads := opts.Threads
+	if threads < 1 {
+		threads = 1
+	}
+	return newParallelGzipWriter(w, threads, level, name, modTime)
+}
+
+// parallelGzipWriter сжимает блоки входных данных одновременно в нескольких горутинах
+// и записывает их в исходном порядке. Каждый блок, кроме последнего, завершается sync flush,
+// поэтому конкатенация блоков образует один deflate-поток. Словарем блока служат последние
+// 32 КБ предыдущего блока, так что степень сжатия почти не отличается от однопоточной
+type parallelGzipWriter struct {
+	level int
+
+	buf  []byte
+	dict []byte
+	crc  uint32
+	size uint32
+
+	queue   chan *gzipBlock
+	workers chan struct{}
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+
+	closed bool
+}
+
+// gzipBlock - блок данных и результат его сжатия
+type gzipBlock struct {
+	data  []byte
+	dict  []byte
+	level int
+	last  bool
+	out   bytes.Buffer
+	err   error
+	done  chan struct{}
+}
+
+func newParallelGzipWriter(w io.Writer, threads, level int, name string, modTime time.Time) *parallelGzipWriter {
+	pw := &parallelGzipWriter{
+		level:   level,
+		buf:     make([]byte, 0, parallelBlockSize),
+		queue:   make(chan *gzipBlock, threads*2),
+		workers: make(chan struct{}, threads),
+		done:    make(chan struct{}),
+	}
+
+	go pw.writeLoop(w, gzipHeader(level, name, modTime))
+	return pw
+}
+
+func (pw *parallelGzipWriter) Write(p []byte) (int, error) {
+	if pw.closed {
+		return 0, errors.New("gzip: write to closed writer")
+	}
+	if err := pw.failed(); err != nil {
+		return 0, err
+	}
+
+	pw.crc = crc32.Update(pw.crc, crc32.IEEETable, p)
+	pw.size += uint32(len(p))
+
+	written := len(p)
+	for len(p) > 0 {
+		n := copy(pw.buf[len(pw.buf):cap(pw.buf)], p)
+		pw.buf = pw.buf[:len(pw.buf)+n]
+		p = p[n:]
+
+		if len(pw.buf) == cap(pw.buf) {
+			pw.dispatch(false)
+		}
+	}
+
+	return written, nil
+}
+
+// Close сжимает оставшиеся данные последним блоком, дожидается записи всех блоков и пишет трейлер gzip
+func (pw *parallelGzipWriter) Close() error {
+	if pw.closed {
+		return pw.failed()
+	}
+	pw.closed = true
+
+	pw.dispatch(true)
+	close(pw.queue)
+	<-pw.done
+
+	return pw.failed()
+}
+
+// setLevel меняет уровень сжатия следующих данных. Накопленные данные сжимаются отдельным
+// блоком с прежним уровнем
+func (pw *parallelGzipWriter) setLevel(level int) {
+	if level == pw.level {
+		return
+	}
+	if len(pw.buf) > 0 {
+		pw.dispatch(false)
+	}
+	pw.level = level
+}
+
+// dispatch отправляет накопленный буфер на сжатие
+func (pw *parallelGzipWriter) dispatch(last bool) {
+	block := &gzipBlock{
+		data:  pw.buf,
+		dict:  pw.dict,
+		level: pw.level,
+		last:  last,
+		done:  make(chan struct{}),
+	}
+
+	// Словарь следующего блока - последние 32 КБ всех записанных данных,
+	// блок короче окна дополняется концом прежнего словаря
+	if len(pw.buf) >= dictionarySize {
+		pw.dict = pw.buf[len(pw.buf)-dictionarySize:]
+	} else {
+		dict := append(append(make([]byte, 0, len(pw.dict)+len(pw.buf)), pw.dict...), pw.buf...)
+		if len(dict) > dictionarySize {
+			dict = dict[len(dict)-dictionarySize:]
+		}
+		pw.dict = dict
+	}
+	pw.buf = make([]byte, 0, parallelBlockSize)
+
+	// Очередь ограничивает количество блоков в памяти, workers - количество одновременно сжимаемых
+	pw.queue <- block
+	pw.workers <- struct{}{}
+	go func() {
+		defer func() { <-pw.workers }()
+		block.compress()
+	}()
+}
+
+// writeLoop записывает заголовок, сжатые блоки в порядке поступления и трейлер gzip
+func (pw *parallelGzipWriter) writeLoop(w io.Writer, header []byte) {
+	defer close(pw.done)
+
+	_, err := w.Write(header)
+	for block := range pw.queue {
+		<-block.done
+		if err != nil {
+			continue
+		}
+		if err = block.err; err == nil {
+			_, err = w.Write(block.out.Bytes())
+		}
+		if err != nil {
+			pw.setErr(err)
+		}
+	}
+	if err != nil {
+		pw.setErr(err)
+		return
+	}
+
+	// Трейлер: CRC32 и размер несжатых данных по модулю 2^32
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[0:4], pw.crc)
+	binary.LittleEndian.PutUint32(trailer[4:8], pw.size)
+	if _, err := w.Write(trailer[:]); err != nil {
+		pw.setErr(err)
+	}
+}
+
+func (pw *parallelGzipWriter) setErr(err error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.err == nil {
+		pw.err = err
+	}
+}
+
+func (pw *parallelGzipWriter) failed() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.err
+}
+
+func (b *gzipBlock) compress() {
+	defer close(b.done)
+
+	writer, err := flate.NewWriterDict(&b.out, b.level, b.dict)
+	if err != nil {
+		b.err = err
+		return
+	}
+
+	if _, err := writer.Write(b.data); err != nil {
+		b.err = err
+		return
+	}
+
+	// Последний блок завершает deflate-поток, остальные выравниваются по байту без признака конца
+	if b.last {
+		b.err = writer.Close()
+	} else {
+		b.err = writer.Flush()
+	}
+}
+
+// gzipHeader формирует заголовок gzip (RFC 1952). Имя, которое нельзя записать в Latin-1, не сохраняется
+func gzipHeader(level int, name string, modTime time.Time) []byte {
+	header := []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 255}
+
+	if !modTime.IsZero() && modTime.Unix() > 0 {
+		binary.LittleEndian.PutUint32(header[4:8], uint32(modTime.Unix()))
+	}
+
+	switch level {
+	case flate.BestCompression:
+		header[8] = 2
+	case flate.BestSpeed:
+		header[8] = 4
+	}
+
+	if latin1, ok := toLatin1(name); ok && latin1 != nil {
+		header[3] |= 0x08
+		header = append(header, latin1...)
+		header = append(header, 0)
+	}
+
+	return header
+}
+
+func toLatin1(s string) ([]byte, bool) {
+	var out []byte
+	for _, r := range s {
+		if r == 0 || r > 0xff {
+			return nil, false
+		}
+		out = append(out, byte(r))
+	}
+	return out, true
+}
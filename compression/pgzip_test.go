@@ -0,0 +1,113 @@
+package compression
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testData возвращает сжимаемые псевдослучайные данные размера size
+func testData(size int) []byte {
+	rng := rand.New(rand.NewSource(int64(size)))
+	words := []string{"backup ", "archive ", "volume ", "restore ", "\n", "goback "}
+
+	var buf bytes.Buffer
+	for buf.Len() < size {
+		if rng.Intn(10) == 0 {
+			buf.WriteByte(byte(rng.Intn(256)))
+			continue
+		}
+		buf.WriteString(words[rng.Intn(len(words))])
+	}
+	return buf.Bytes()[:size]
+}
+
+func TestParallelGzipWriter(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, size := range []int{0, 1, parallelBlockSize - 1, parallelBlockSize, parallelBlockSize + 1, 3*parallelBlockSize + 12345} {
+		data := testData(size)
+
+		var out bytes.Buffer
+		w := newParallelGzipWriter(&out, 4, flate.DefaultCompression, "dump.sql", modTime)
+		// Запись кусками разного размера, не совпадающими с границами блоков
+		for rest := data; len(rest) > 0; {
+			n := min(len(rest), 100000+len(rest)%7777)
+			if _, err := w.Write(rest[:n]); err != nil {
+				t.Fatal(err)
+			}
+			rest = rest[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := gzip.NewReader(&out)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		r.Multistream(false)
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: read back: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("size %d: data mismatch after round trip (%d bytes read)", size, len(got))
+		}
+		if r.Name != "dump.sql" || !r.ModTime.Equal(modTime) {
+			t.Errorf("size %d: header name %q, mtime %v", size, r.Name, r.ModTime)
+		}
+		if out.Len() != 0 {
+			t.Errorf("size %d: %d bytes after gzip stream", size, out.Len())
+		}
+	}
+}
+
+func TestParallelGzipWriterSetLevel(t *testing.T) {
+	first := testData(parallelBlockSize / 2)
+	second := testData(parallelBlockSize/2 + 1)
+
+	var out bytes.Buffer
+	w := newParallelGzipWriter(&out, 2, flate.BestCompression, "", time.Time{})
+	if _, err := w.Write(first); err != nil {
+		t.Fatal(err)
+	}
+	w.setLevel(flate.NoCompression)
+	if _, err := w.Write(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Несжатая вторая половина делает поток не меньше ее размера
+	if out.Len() < len(second) {
+		t.Errorf("output %d bytes, stored part alone is %d bytes", out.Len(), len(second))
+	}
+
+	r, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, append(first, second...)) {
+		t.Fatal("data mismatch after level switch")
+	}
+}
+
+func TestParallelGzipWriterClosed(t *testing.T) {
+	w := newParallelGzipWriter(io.Discard, 2, flate.DefaultCompression, "", time.Time{})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("late")); err == nil {
+		t.Error("write after Close succeeded")
+	}
+}
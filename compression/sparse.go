@@ -0,0 +1,198 @@
+package compression
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// tarBlockSize - размер блока tar
+	tarBlockSize = 512
+	// maxUSTAROctal - максимальное значение числового поля размера в заголовке ustar (11 восьмеричных цифр)
+	maxUSTAROctal = 1<<33 - 1
+	// paxXattrPrefix - префикс записей PAX с расширенными атрибутами
+	paxXattrPrefix = "SCHILY.xattr."
+)
+
+// sparseSegment - участок разреженного файла с данными
+type sparseSegment struct {
+	offset int64
+	length int64
+}
+
+// writeSparseFile записывает разреженный файл в формате PAX sparse 1.0 (как GNU tar --sparse --format=posix):
+// расширенный заголовок с настоящим именем и размером файла, заголовок ustar, карта участков и только
+// участки с данными. archive/tar не умеет записывать такие файлы, поэтому блоки пишутся напрямую в w
+// между элементами tw. r читает файл, file позиционирует его на начало участков
+func writeSparseFile(tw *tar.Writer, w io.Writer, header *tar.Header, file io.Seeker, r io.Reader, segments []sparseSegment, recorder *fileRecorder) error {
+	realSize := header.Size
+
+	// Карта всегда заканчивается на размере файла, чтобы сохранить дыру в конце
+	if len(segments) == 0 || segments[len(segments)-1].offset+segments[len(segments)-1].length < realSize {
+		segments = append(segments, sparseSegment{offset: realSize})
+	}
+
+	var sparseMap bytes.Buffer
+	fmt.Fprintf(&sparseMap, "%d\n", len(segments))
+	var dataSize int64
+	for _, segment := range segments {
+		fmt.Fprintf(&sparseMap, "%d\n%d\n", segment.offset, segment.length)
+		dataSize += segment.length
+	}
+	sparseMap.Write(make([]byte, padding(int64(sparseMap.Len()))))
+
+	records := map[string]string{
+		"GNU.sparse.major":    "1",
+		"GNU.sparse.minor":    "0",
+		"GNU.sparse.name":     header.Name,
+		"GNU.sparse.realsize": strconv.FormatInt(realSize, 10),
+	}
+	for key, value := range header.PAXRecords {
+		records[key] = value
+	}
+
+	ustar := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     shortName(path.Join(path.Dir(header.Name), "GNUSparseFile.0", path.Base(header.Name))),
+		Mode:     header.Mode,
+		Uid:      header.Uid,
+		Gid:      header.Gid,
+		Uname:    header.Uname,
+		Gname:    header.Gname,
+		Size:     int64(sparseMap.Len()) + dataSize,
+		ModTime:  header.ModTime.Truncate(time.Second),
+		Format:   tar.FormatUSTAR,
+	}
+
+	// Значения, которые не помещаются в поля ustar, передаются записями PAX
+	if ustar.Size > maxUSTAROctal {
+		records["size"] = strconv.FormatInt(ustar.Size, 10)
+		ustar.Size = 0
+	}
+	if len(ustar.Uname) > 31 {
+		records["uname"] = ustar.Uname
+		ustar.Uname = ""
+	}
+	if len(ustar.Gname) > 31 {
+		records["gname"] = ustar.Gname
+		ustar.Gname = ""
+	}
+	if ustar.Uid > 07777777 {
+		records["uid"] = strconv.Itoa(ustar.Uid)
+		ustar.Uid = 0
+	}
+	if ustar.Gid > 07777777 {
+		records["gid"] = strconv.Itoa(ustar.Gid)
+		ustar.Gid = 0
+	}
+
+	paxData := formatPAXRecords(records)
+	paxBlock, err := encodeHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     shortName(path.Join(path.Dir(header.Name), "PaxHeaders.0", path.Base(header.Name))),
+		Mode:     0644,
+		Size:     int64(len(paxData)),
+		ModTime:  ustar.ModTime,
+		Format:   tar.FormatUSTAR,
+	})
+	if err != nil {
+		return err
+	}
+	paxBlock[156] = tar.TypeXHeader
+	setChecksum(paxBlock)
+
+	ustarBlock, err := encodeHeader(ustar)
+	if err != nil {
+		return err
+	}
+
+	// Дополняем предыдущий элемент до границы блока
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, data := range [][]byte{paxBlock, paxData, make([]byte, padding(int64(len(paxData)))), ustarBlock, sparseMap.Bytes()} {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	var position int64
+	for _, segment := range segments {
+		// Дыры читаются как нули, поэтому учитываем их в контрольной сумме манифеста
+		recorder.zeros(segment.offset - position)
+		if _, err := file.Seek(segment.offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, segment.length); err != nil {
+			return fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		position = segment.offset + segment.length
+	}
+	recorder.zeros(realSize - position)
+
+	_, err = w.Write(make([]byte, padding(dataSize)))
+	return err
+}
+
+// encodeHeader возвращает блок заголовка, сформированный archive/tar
+func encodeHeader(header *tar.Header) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tar.NewWriter(&buf).WriteHeader(header); err != nil {
+		return nil, err
+	}
+	if buf.Len() != tarBlockSize {
+		return nil, fmt.Errorf("unexpected tar header size %d for %s", buf.Len(), header.Name)
+	}
+	return buf.Bytes(), nil
+}
+
+// setChecksum пересчитывает контрольную сумму блока заголовка после его изменения
+func setChecksum(block []byte) {
+	copy(block[148:156], "        ")
+	var sum int64
+	for _, b := range block {
+		sum += int64(b)
+	}
+	copy(block[148:156], fmt.Sprintf("%06o\x00 ", sum))
+}
+
+// formatPAXRecords кодирует записи расширенного заголовка PAX ("<длина> <ключ>=<значение>\n")
+func formatPAXRecords(records map[string]string) []byte {
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		// Длина записи включает запись собственной длины
+		size := len(key) + len(records[key]) + 3
+		size += len(strconv.Itoa(size))
+		record := fmt.Sprintf("%d %s=%s\n", size, key, records[key])
+		if len(record) != size {
+			record = fmt.Sprintf("%d %s=%s\n", len(record), key, records[key])
+		}
+		buf.WriteString(record)
+	}
+	return buf.Bytes()
+}
+
+// shortName укорачивает служебное имя элемента до размера поля имени ustar
+func shortName(name string) string {
+	if len(name) <= 100 {
+		return name
+	}
+	return name[len(name)-100:]
+}
+
+func padding(size int64) int64 {
+	return -size & (tarBlockSize - 1)
+}
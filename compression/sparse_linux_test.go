@@ -0,0 +1,97 @@
+//go:build linux
+
+package compression
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"goback/storage"
+)
+
+func TestSparseRoundTrip(t *testing.T) {
+	source := t.TempDir()
+	path := filepath.Join(source, "disk.img")
+
+	const size = 8 << 20
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	head := bytes.Repeat([]byte("head"), 1024)
+	middle := bytes.Repeat([]byte("middle"), 2048)
+	if _, err := file.WriteAt(head, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt(middle, 4<<20); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSparse(info) {
+		t.Skip("file system does not support sparse files")
+	}
+
+	b, destination := roundTrip(t, "tar", Options{}, ReadOptions{}, source, "disk.tar")
+
+	archive, err := b.Stat("disk.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.Size() > 1<<20 {
+		t.Errorf("archive is %d bytes, holes were stored as data", archive.Size())
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoredPath := filepath.Join(destination, "disk.img")
+	got, err := os.ReadFile(restoredPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("restored sparse file differs from the source")
+	}
+
+	restored, err := os.Stat(restoredPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSparse(restored) {
+		t.Error("restored file is not sparse")
+	}
+
+	// Архив должен читаться и GNU tar, если он установлен
+	if _, err := exec.LookPath("tar"); err == nil {
+		gnuDir := t.TempDir()
+		archivePath := storage.Location(b, "disk.tar")
+		if out, err := exec.Command("tar", "-xf", archivePath, "-C", gnuDir).CombinedOutput(); err != nil {
+			t.Fatalf("tar -xf: %v: %s", err, out)
+		}
+		got, err := os.ReadFile(filepath.Join(gnuDir, "disk.img"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Error("file extracted by tar differs from the source")
+		}
+	}
+}
+
+func isSparse(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Blocks*512 < info.Size()
+}
@@ -0,0 +1,94 @@
+package compression
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// sniffSize - объем начала файла, по которому оценивается энтропия
+	sniffSize = 64 << 10
+	// sniffEntropy - энтропия (бит на байт), начиная с которой данные считаются уже сжатыми
+	sniffEntropy = 7.5
+)
+
+// DefaultStoreExtensions - расширения файлов, которые уже сжаты своим форматом
+// (изображения, видео, аудио, архивы, офисные документы)
+var DefaultStoreExtensions = []string{
+	".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic", ".avif",
+	".mp4", ".m4v", ".mkv", ".mov", ".avi", ".webm",
+	".mp3", ".m4a", ".aac", ".ogg", ".opus", ".flac",
+	".zip", ".gz", ".tgz", ".bz2", ".xz", ".zst", ".lz4", ".7z", ".rar",
+	".docx", ".xlsx", ".pptx", ".odt", ".ods", ".jar", ".apk",
+}
+
+// StorePolicy определяет уже сжатые файлы, которые сохраняются без повторного сжатия
+type StorePolicy struct {
+	// Extensions - расширения таких файлов в нижнем регистре, с точкой
+	Extensions []string
+	// Sniff включает оценку энтропии начала файла для файлов с другими расширениями
+	Sniff bool
+	// TarGz включает сохранение таких файлов без сжатия и в tar.gz
+	TarGz bool
+}
+
+// StoreStats - количество и размер файлов, сохраненных без повторного сжатия
+type StoreStats struct {
+	Files int
+	Bytes int64
+}
+
+// Add прибавляет статистику другого архива
+func (s *StoreStats) Add(other StoreStats) {
+	s.Files += other.Files
+	s.Bytes += other.Bytes
+}
+
+// storeReader возвращает reader файла и признак того, что файл уже сжат и его нужно сохранить
+// без сжатия. Для оценки энтропии начало файла читается в буфер, reader возвращает его повторно
+func (o Options) storeReader(name string, r io.Reader) (io.Reader, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, storeExt := range o.Store.Extensions {
+		if ext == storeExt {
+			return r, true
+		}
+	}
+
+	if !o.Store.Sniff {
+		return r, false
+	}
+
+	buffered := bufio.NewReaderSize(r, sniffSize)
+	sample, _ := buffered.Peek(sniffSize)
+	// По маленьким файлам энтропия оценивается ненадежно, а выигрыш от них незаметен
+	return buffered, len(sample) == sniffSize && entropy(sample) >= sniffEntropy
+}
+
+// countStored учитывает файл, сохраненный без сжатия
+func (o Options) countStored(size int64) {
+	if o.StoreStats != nil {
+		o.StoreStats.Files++
+		o.StoreStats.Bytes += size
+	}
+}
+
+// entropy возвращает энтропию Шеннона данных в битах на байт
+func entropy(data []byte) float64 {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	var result float64
+	total := float64(len(data))
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / total
+			result -= p * math.Log2(p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package compression
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"goback/storage"
+)
+
+// randomData возвращает несжимаемые данные размера size
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func TestEntropy(t *testing.T) {
+	if got := entropy(make([]byte, 1024)); got != 0 {
+		t.Errorf("entropy of zeros = %v, want 0", got)
+	}
+
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	if got := entropy(all); got != 8 {
+		t.Errorf("entropy of all byte values = %v, want 8", got)
+	}
+
+	if got := entropy(testData(sniffSize)); got >= sniffEntropy {
+		t.Errorf("entropy of text = %v, want below %v", got, sniffEntropy)
+	}
+	if got := entropy(randomData(sniffSize)); got < sniffEntropy {
+		t.Errorf("entropy of random data = %v, want at least %v", got, sniffEntropy)
+	}
+}
+
+func TestStoreReader(t *testing.T) {
+	random := randomData(sniffSize * 2)
+	text := testData(sniffSize * 2)
+	sniff := Options{Store: StorePolicy{Extensions: []string{".jpg"}, Sniff: true}}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		file  string
+		data  []byte
+		store bool
+	}{
+		{name: "extension", opts: sniff, file: "photo.jpg", data: text, store: true},
+		{name: "extension in upper case", opts: sniff, file: "PHOTO.JPG", data: text, store: true},
+		{name: "random data", opts: sniff, file: "data.bin", data: random, store: true},
+		{name: "text", opts: sniff, file: "data.bin", data: text},
+		// Начало файла меньше sniffSize не оценивается
+		{name: "small random file", opts: sniff, file: "data.bin", data: random[:sniffSize-1]},
+		{name: "sniff disabled", opts: Options{Store: StorePolicy{Extensions: []string{".jpg"}}}, file: "data.bin", data: random},
+	}
+
+	for _, tt := range tests {
+		reader, store := tt.opts.storeReader(tt.file, bytes.NewReader(tt.data))
+		if store != tt.store {
+			t.Errorf("%s: store = %v, want %v", tt.name, store, tt.store)
+		}
+
+		// Прочитанное для оценки начало файла не теряется
+		got, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(got, tt.data) {
+			t.Errorf("%s: reader returned %d bytes, %v, want %d", tt.name, len(got), err, len(tt.data))
+		}
+	}
+}
+
+// storeTestFiles - уже сжатые и сжимаемые файлы для проверки StorePolicy
+func storeTestFiles() map[string]string {
+	return map[string]string{
+		"photo.jpg":  string(testData(256 << 10)),
+		"random.bin": string(randomData(128 << 10)),
+		"notes.txt":  string(testData(128 << 10)),
+	}
+}
+
+func TestZipStore(t *testing.T) {
+	files := storeTestFiles()
+	source := t.TempDir()
+	if err := writeTestFiles(source, files); err != nil {
+		t.Fatal(err)
+	}
+
+	var stats StoreStats
+	opts := Options{Store: StorePolicy{Extensions: DefaultStoreExtensions, Sniff: true}, StoreStats: &stats}
+	b, destination := roundTrip(t, "zip", opts, ReadOptions{}, source, "site.zip")
+	checkTestFiles(t, destination, files)
+
+	data, err := storage.ReadFile(b, "site.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]uint16{"photo.jpg": zip.Store, "random.bin": zip.Store, "notes.txt": zip.Deflate}
+	for _, f := range zr.File {
+		if method, ok := want[f.Name]; ok && f.Method != method {
+			t.Errorf("%s: method %d, want %d", f.Name, f.Method, method)
+		}
+	}
+
+	wantStats := StoreStats{Files: 2, Bytes: int64(len(files["photo.jpg"]) + len(files["random.bin"]))}
+	if stats != wantStats {
+		t.Errorf("StoreStats = %+v, want %+v", stats, wantStats)
+	}
+}
+
+func TestTarGzStore(t *testing.T) {
+	files := map[string]string{"photo.jpg": string(testData(256 << 10))}
+	source := t.TempDir()
+	if err := writeTestFiles(source, files); err != nil {
+		t.Fatal(err)
+	}
+
+	archiveSize := func(policy StorePolicy) (int64, StoreStats) {
+		t.Helper()
+
+		var stats StoreStats
+		b, destination := roundTrip(t, "tar.gz", Options{Store: policy, StoreStats: &stats}, ReadOptions{}, source, "site.tar.gz")
+		checkTestFiles(t, destination, files)
+
+		data, err := storage.ReadFile(b, "site.tar.gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int64(len(data)), stats
+	}
+
+	// Без TarGz расширение не учитывается и сжимаемое содержимое сжимается
+	compressed, stats := archiveSize(StorePolicy{Extensions: DefaultStoreExtensions})
+	if stats != (StoreStats{}) {
+		t.Errorf("StoreStats without TarGz = %+v", stats)
+	}
+
+	stored, stats := archiveSize(StorePolicy{Extensions: DefaultStoreExtensions, TarGz: true})
+	if want := (StoreStats{Files: 1, Bytes: 256 << 10}); stats != want {
+		t.Errorf("StoreStats = %+v, want %+v", stats, want)
+	}
+	if stored < 256<<10 || compressed*2 > stored {
+		t.Errorf("archive sizes: %d with stored file, %d compressed", stored, compressed)
+	}
+}
@@ -0,0 +1,177 @@
+//go:build linux
+
+package compression
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Значения whence для lseek, которые находят участки данных и дыры разреженного файла,
+// и флаги utimensat
+const (
+	seekData = 3
+	seekHole = 4
+
+	atFDCWD           = -0x64
+	atSymlinkNoFollow = 0x100
+)
+
+// FileID идентифицирует файл на диске для поиска жестких ссылок
+type FileID struct {
+	dev uint64
+	ino uint64
+}
+
+// HardlinkID возвращает идентификатор файла, если на него есть несколько жестких ссылок
+func HardlinkID(info os.FileInfo) (FileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return FileID{}, false
+	}
+	return FileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// fileOwner возвращает uid и gid владельца файла
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// readXattrs читает расширенные атрибуты файла или директории. Listxattr переходит по симлинкам,
+// поэтому для симлинков функция не вызывается
+func readXattrs(path string) (map[string]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string]string)
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name == "" {
+			continue
+		}
+
+		valueSize, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		value := make([]byte, valueSize)
+		if valueSize > 0 {
+			if valueSize, err = syscall.Getxattr(path, name, value); err != nil {
+				return nil, err
+			}
+		}
+		xattrs[name] = string(value[:valueSize])
+	}
+
+	return xattrs, nil
+}
+
+// writeXattr устанавливает расширенный атрибут самого элемента, не переходя по симлинку.
+// Атрибуты, которые файловая система не поддерживает или которые нельзя установить
+// без прав root (trusted.*, security.*), пропускаются
+func writeXattr(path, name, value string) error {
+	err := lsetxattr(path, name, []byte(value))
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM) {
+		return nil
+	}
+	return err
+}
+
+// lsetxattr - аналог syscall.Setxattr, который не переходит по симлинкам
+func lsetxattr(path, name string, value []byte) error {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	var valuePtr unsafe.Pointer
+	if len(value) > 0 {
+		valuePtr = unsafe.Pointer(&value[0])
+	}
+
+	_, _, errno := syscall.Syscall6(syscall.SYS_LSETXATTR, uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(namePtr)),
+		uintptr(valuePtr), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// sparseSegments возвращает участки с данными разреженного файла. Для обычного файла
+// или файловой системы без SEEK_DATA/SEEK_HOLE возвращается false
+func sparseSegments(file *os.File, info os.FileInfo) ([]sparseSegment, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	size := info.Size()
+	if !ok || size == 0 || st.Blocks*512 >= size {
+		return nil, false
+	}
+
+	var segments []sparseSegment
+	for offset := int64(0); offset < size; {
+		data, err := file.Seek(offset, seekData)
+		if errors.Is(err, syscall.ENXIO) {
+			// До конца файла только дыра
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+
+		hole, err := file.Seek(data, seekHole)
+		if err != nil {
+			return nil, false
+		}
+		if hole > size {
+			hole = size
+		}
+
+		if hole > data {
+			segments = append(segments, sparseSegment{offset: data, length: hole - data})
+		}
+		offset = hole
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, false
+	}
+
+	return segments, true
+}
+
+// lchtimes выставляет время модификации самого симлинка, а не его цели
+func lchtimes(path string, t time.Time) error {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	ts := [2]syscall.Timespec{syscall.NsecToTimespec(t.UnixNano()), syscall.NsecToTimespec(t.UnixNano())}
+	dirfd := atFDCWD
+	_, _, errno := syscall.Syscall6(syscall.SYS_UTIMENSAT, uintptr(dirfd), uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&ts[0])), atSymlinkNoFollow, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build !linux
+
+package compression
+
+import (
+	"os"
+	"time"
+)
+
+// FileID идентифицирует файл на диске для поиска жестких ссылок
+type FileID struct {
+	dev uint64
+	ino uint64
+}
+
+// HardlinkID на этой платформе не поддерживается: жесткие ссылки сохраняются как отдельные файлы
+func HardlinkID(info os.FileInfo) (FileID, bool) {
+	return FileID{}, false
+}
+
+// fileOwner на этой платформе не поддерживается
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
+
+// readXattrs на этой платформе не поддерживается
+func readXattrs(path string) (map[string]string, error) {
+	return nil, nil
+}
+
+// writeXattr на этой платформе не поддерживается
+func writeXattr(path, name, value string) error {
+	return nil
+}
+
+// sparseSegments на этой платформе не поддерживается: файл записывается целиком
+func sparseSegments(file *os.File, info os.FileInfo) ([]sparseSegment, bool) {
+	return nil, false
+}
+
+// lchtimes на этой платформе не поддерживается
+func lchtimes(path string, t time.Time) error {
+	return nil
+}
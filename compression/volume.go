@@ -0,0 +1,286 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"goback/encryption"
+	"goback/storage"
+	"goback/utils"
+)
+
+// archiveWriter - записываемый архив. Close сохраняет архив в хранилище, Abort отменяет запись
+// и удаляет уже сохраненные тома; после Close Abort ничего не делает
+type archiveWriter interface {
+	io.WriteCloser
+	Abort()
+}
+
+// createArchive создает архив destination в хранилище opts.Storage. При opts.SplitSize > 0 данные
+// записываются в тома destination.001, destination.002, ... размером не больше SplitSize,
+// при заданных opts.Encryption - шифруются перед записью
+func createArchive(destination string, opts Options) (archiveWriter, error) {
+	var file archiveWriter
+	if opts.SplitSize > 0 {
+		w := &volumeWriter{storage: opts.Storage, name: destination, size: opts.SplitSize}
+		w.next()
+		file = w
+	} else {
+		file = storage.Create(opts.Storage, destination)
+	}
+
+	if len(opts.Encryption) == 0 {
+		return file, nil
+	}
+
+	encrypted, err := encryption.NewWriter(file, opts.Encryption...)
+	if err != nil {
+		// Не оставляем пустой архив, например, если не задана парольная фраза
+		file.Abort()
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return &encryptedWriter{WriteCloser: encrypted, file: file}, nil
+}
+
+// encryptedWriter шифрует данные в архив. Close дописывает последний зашифрованный блок
+// и сохраняет архив; повторный вызов ничего не делает
+type encryptedWriter struct {
+	io.WriteCloser
+	file   archiveWriter
+	closed bool
+}
+
+func (w *encryptedWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if err := w.WriteCloser.Close(); err != nil {
+		w.file.Abort()
+		return err
+	}
+	return w.file.Close()
+}
+
+func (w *encryptedWriter) Abort() {
+	w.closed = true
+	w.file.Abort()
+}
+
+// volumeWriter записывает поток в последовательность томов фиксированного размера
+type volumeWriter struct {
+	storage storage.Backend
+	name    string
+	size    int64
+	count   int
+	current *storage.Writer
+	written int64
+	// saved - уже сохраненные тома
+	saved  []string
+	closed bool
+}
+
+func (w *volumeWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		if w.written == w.size {
+			if err := w.next(); err != nil {
+				return total, err
+			}
+		}
+
+		chunk := p
+		if rest := w.size - w.written; int64(len(chunk)) > rest {
+			chunk = chunk[:rest]
+		}
+
+		n, err := w.current.Write(chunk)
+		total += n
+		w.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// next сохраняет текущий том и начинает следующий
+func (w *volumeWriter) next() error {
+	if w.current != nil {
+		if err := w.current.Close(); err != nil {
+			return err
+		}
+		w.saved = append(w.saved, utils.VolumeName(w.name, w.count))
+	}
+
+	w.count++
+	w.current = storage.Create(w.storage, utils.VolumeName(w.name, w.count))
+	w.written = 0
+	return nil
+}
+
+func (w *volumeWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.current.Close()
+}
+
+func (w *volumeWriter) Abort() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+
+	w.current.Abort()
+	for _, volume := range w.saved {
+		w.storage.Delete(volume)
+	}
+}
+
+// archiveFile - открытый архив: отдельный объект или тома, которые читаются как один файл
+type archiveFile interface {
+	io.Reader
+	io.ReaderAt
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
+// openArchive открывает архив source в хранилище b; архив, разбитый на тома,
+// читается как их конкатенация
+func openArchive(b storage.Backend, source string) (archiveFile, error) {
+	names, err := storage.Volumes(b, source)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 1 && names[0] == source {
+		return b.Open(source)
+	}
+
+	r := &volumeReader{names: names}
+	for _, name := range names {
+		file, err := b.Open(name)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			r.Close()
+			return nil, err
+		}
+
+		r.volumes = append(r.volumes, file)
+		r.offsets = append(r.offsets, r.size)
+		r.size += info.Size()
+		r.info = info
+	}
+	return r, nil
+}
+
+// volumeReader читает тома архива как один файл
+type volumeReader struct {
+	names   []string
+	volumes []storage.File
+	offsets []int64
+	size    int64
+	info    os.FileInfo
+	pos     int64
+}
+
+func (r *volumeReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *volumeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	total := 0
+	for len(p) > 0 && off < r.size {
+		// Том, которому принадлежит смещение off
+		i := sort.Search(len(r.offsets), func(i int) bool { return r.offsets[i] > off }) - 1
+
+		n, err := r.volumes[i].ReadAt(p, off-r.offsets[i])
+		total += n
+		off += int64(n)
+		p = p[n:]
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+		if err == io.EOF && n == 0 {
+			return total, fmt.Errorf("volume %s is shorter than expected", path.Base(r.names[i]))
+		}
+	}
+
+	if len(p) > 0 {
+		return total, io.EOF
+	}
+	return total, nil
+}
+
+func (r *volumeReader) Close() error {
+	var firstErr error
+	for _, file := range r.volumes {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Stat возвращает сведения о последнем томе с общим размером архива
+func (r *volumeReader) Stat() (os.FileInfo, error) {
+	return volumeInfo{FileInfo: r.info, size: r.size}, nil
+}
+
+// decryptArchive возвращает расшифрованное содержимое открытого архива .enc
+func decryptArchive(file archiveFile, identities []encryption.Identity) (archiveFile, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	reader, err := encryption.NewReader(file, info.Size(), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt archive: %w", err)
+	}
+
+	return &decryptedFile{Reader: reader, info: info}, nil
+}
+
+// decryptedFile - расшифрованный архив; файл закрывает тот, кто его открыл
+type decryptedFile struct {
+	*encryption.Reader
+	info os.FileInfo
+}
+
+func (f *decryptedFile) Close() error {
+	return nil
+}
+
+func (f *decryptedFile) Stat() (os.FileInfo, error) {
+	return volumeInfo{FileInfo: f.info, size: f.Size()}, nil
+}
+
+type volumeInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (i volumeInfo) Size() int64 {
+	return i.size
+}
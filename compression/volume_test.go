@@ -0,0 +1,171 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"goback/storage"
+)
+
+func writeVolumes(t *testing.T, b storage.Backend, name string, size int64, data []byte) {
+	t.Helper()
+
+	w, err := createArchive(name, Options{Storage: b, SplitSize: size})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Запись кусками, не совпадающими с границами томов
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), 7)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVolumeWriter(t *testing.T) {
+	tests := []struct {
+		size  int
+		sizes []int64
+	}{
+		{size: 35, sizes: []int64{10, 10, 10, 5}},
+		{size: 30, sizes: []int64{10, 10, 10}},
+		{size: 3, sizes: []int64{3}},
+		{size: 0, sizes: []int64{0}},
+	}
+
+	for _, tt := range tests {
+		b := storage.NewLocal(t.TempDir())
+		data := testData(tt.size)
+		writeVolumes(t, b, "app.tar", 10, data)
+
+		names, err := storage.Volumes(b, "app.tar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != len(tt.sizes) {
+			t.Fatalf("%d bytes: volumes %v, want %d", tt.size, names, len(tt.sizes))
+		}
+		for i, name := range names {
+			info, err := b.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != tt.sizes[i] {
+				t.Errorf("%d bytes: %s is %d bytes, want %d", tt.size, name, info.Size(), tt.sizes[i])
+			}
+		}
+
+		file, err := openArchive(b, "app.tar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%d bytes: volumes read back as %q, want %q", tt.size, got, data)
+		}
+	}
+}
+
+func TestVolumeReaderReadAt(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+	data := testData(35)
+	writeVolumes(t, b, "app.zip", 10, data)
+
+	file, err := openArchive(b, "app.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Errorf("Stat size = %d, want %d", info.Size(), len(data))
+	}
+
+	tests := []struct {
+		off     int64
+		length  int
+		wantErr error
+	}{
+		{off: 0, length: 10},
+		{off: 8, length: 4},
+		{off: 5, length: 25},
+		{off: 30, length: 5},
+		{off: 30, length: 10, wantErr: io.EOF},
+		{off: 35, length: 1, wantErr: io.EOF},
+	}
+
+	for _, tt := range tests {
+		buf := make([]byte, tt.length)
+		n, err := file.ReadAt(buf, tt.off)
+		if err != tt.wantErr {
+			t.Errorf("ReadAt(%d, %d) error = %v, want %v", tt.off, tt.length, err, tt.wantErr)
+		}
+		end := min(int(tt.off)+tt.length, len(data))
+		if !bytes.Equal(buf[:n], data[tt.off:end]) {
+			t.Errorf("ReadAt(%d, %d) = %q, want %q", tt.off, tt.length, buf[:n], data[tt.off:end])
+		}
+	}
+}
+
+func TestVolumeWriterAbort(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+
+	w, err := createArchive("app.tar", Options{Storage: b, SplitSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(testData(25)); err != nil {
+		t.Fatal(err)
+	}
+	w.Abort()
+
+	entries, err := b.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("volumes left after Abort: %v", names)
+	}
+}
+
+func TestSplitArchiveRoundTrip(t *testing.T) {
+	// Случайные данные не сжимаются, поэтому архив занимает несколько томов
+	random := make([]byte, 3<<20)
+	rand.New(rand.NewSource(1)).Read(random)
+	files := map[string]string{"a.bin": string(random), "b/c.txt": "small"}
+
+	source := t.TempDir()
+	if err := writeTestFiles(source, files); err != nil {
+		t.Fatal(err)
+	}
+
+	b, destination := roundTrip(t, "tar.gz", Options{SplitSize: 1 << 20}, ReadOptions{}, source, "site.tar.gz")
+
+	names, err := storage.Volumes(b, "site.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) < 2 {
+		t.Errorf("archive written as %v, want several volumes", names)
+	}
+	checkTestFiles(t, destination, files)
+}
@@ -0,0 +1,214 @@
+package compression
+
+import (
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"goback/encryption"
+	"goback/manifest"
+	"goback/storage"
+	"goback/utils"
+)
+
+// Options - параметры создания архива
+type Options struct {
+	// Exclude сообщает, нужно ли пропустить путь относительно source при обходе директории
+	Exclude func(relPath string) bool
+	// Manifest, если задан, заполняется списком файлов, попавших в архив
+	Manifest *manifest.Manifest
+	// Threads - количество потоков сжатия для gzip и tar.gz; 0 и 1 - однопоточное сжатие
+	Threads int
+	// Level - уровень сжатия gzip, zip и tar.gz в формате compression_level (см. ParseLevel)
+	Level string
+	// Filter - внешняя программа сжатия для типа filter
+	Filter *Filter
+	// SplitSize - максимальный размер тома архива; 0 - архив записывается одним файлом
+	SplitSize int64
+	// Store - уже сжатые файлы, которые сохраняются без повторного сжатия
+	Store StorePolicy
+	// StoreStats, если задан, заполняется статистикой файлов, сохраненных без сжатия
+	StoreStats *StoreStats
+	// Encryption - получатели, для которых шифруется архив; пусто - архив не шифруется
+	Encryption []encryption.Recipient
+	// Storage - хранилище, в которое записывается архив; destination компрессора - имя объекта в нем
+	Storage storage.Backend
+}
+
+// level возвращает уровень deflate; некорректное значение отсекается при проверке конфигурации
+func (o Options) level() int {
+	level, err := ParseLevel(o.Level)
+	if err != nil {
+		return flate.DefaultCompression
+	}
+	return level
+}
+
+// walkSource обходит директорию source, пропуская специальные файлы (socket, named pipe, device files)
+// и пути, исключенные через Exclude. Недоступные файлы и директории пропускаются с предупреждением
+// и отмечаются в манифесте (см. SkipUnreadable). Для остальных элементов вызывается fn
+// с абсолютным путем и путем относительно source
+func (o Options) walkSource(source string, fn func(path, relPath string, info os.FileInfo) error) error {
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for source: %w", err)
+	}
+
+	if _, err := os.Stat(absSource); err != nil {
+		return fmt.Errorf("failed to stat source: %w", err)
+	}
+
+	return filepath.Walk(absSource, func(path string, info os.FileInfo, walkErr error) error {
+		relPath, err := filepath.Rel(absSource, path)
+		if err != nil {
+			return err
+		}
+
+		if walkErr != nil {
+			// Бэкап без содержимого source не должен выглядеть успешным
+			if relPath == "." {
+				return fmt.Errorf("failed to read source: %w", walkErr)
+			}
+			if info == nil || !info.IsDir() {
+				SkipUnreadable(o.Manifest, relPath, false, walkErr)
+				return nil
+			}
+		}
+
+		// Пропускаем корневую директорию (relPath == ".")
+		if relPath == "." {
+			return nil
+		}
+
+		mode := info.Mode()
+		if mode&os.ModeSocket != 0 || mode&os.ModeNamedPipe != 0 || mode&os.ModeDevice != 0 {
+			if o.Manifest != nil {
+				o.Manifest.AddSpecial(filepath.ToSlash(relPath))
+			}
+			return nil
+		}
+
+		if o.Exclude != nil && o.Exclude(relPath) {
+			if info.IsDir() {
+				if o.Manifest != nil {
+					o.Manifest.AddExcluded(filepath.ToSlash(relPath) + "/")
+				}
+				return filepath.SkipDir
+			}
+			if o.Manifest != nil {
+				o.Manifest.AddExcluded(filepath.ToSlash(relPath))
+			}
+			return nil
+		}
+
+		if walkErr != nil {
+			// Директория, которую не удалось прочитать, записывается без содержимого
+			SkipUnreadable(o.Manifest, relPath, true, walkErr)
+		}
+
+		if o.Manifest != nil {
+			switch {
+			case info.IsDir():
+				// Директории записываются в манифест, чтобы diff показывал и пустые директории
+				o.Manifest.AddFile(manifest.File{
+					Path:    filepath.ToSlash(relPath),
+					Type:    manifest.TypeDirectory,
+					Mode:    manifest.FormatMode(mode),
+					ModTime: info.ModTime(),
+				})
+			case mode&os.ModeSymlink != 0:
+				target, err := os.Readlink(path)
+				if err != nil {
+					SkipUnreadable(o.Manifest, relPath, false, err)
+					return nil
+				}
+				o.Manifest.AddFile(manifest.File{
+					Path:    filepath.ToSlash(relPath),
+					Type:    manifest.TypeSymlink,
+					Mode:    manifest.FormatMode(mode),
+					ModTime: info.ModTime(),
+					Link:    target,
+				})
+			}
+		}
+
+		return fn(path, relPath, info)
+	})
+}
+
+// SkipUnreadable выводит предупреждение о файле или директории relPath, которые не удалось прочитать,
+// и отмечает путь в манифесте m, если он задан. Файл, удаленный после обхода директории,
+// просто не попадает в архив
+func SkipUnreadable(m *manifest.Manifest, relPath string, dir bool, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	name := filepath.ToSlash(relPath)
+	if dir {
+		name += "/"
+	}
+	utils.Printf("Warning: skipping unreadable %s: %v\n", name, err)
+
+	if m != nil {
+		m.AddSkipped(name)
+	}
+}
+
+// fileRecorder считает SHA-256 содержимого файла при записи в архив для манифеста
+type fileRecorder struct {
+	manifest *manifest.Manifest
+	hash     hash.Hash
+}
+
+// newFileRecorder возвращает reader, через который нужно читать файл, и recorder для записи в манифест.
+// Без манифеста reader возвращается без изменений
+func (o Options) newFileRecorder(r io.Reader) (io.Reader, *fileRecorder) {
+	if o.Manifest == nil {
+		return r, nil
+	}
+
+	recorder := &fileRecorder{manifest: o.Manifest, hash: sha256.New()}
+	return io.TeeReader(r, recorder.hash), recorder
+}
+
+// zeros учитывает в контрольной сумме n нулевых байт (дыру разреженного файла)
+func (r *fileRecorder) zeros(n int64) {
+	if r == nil || n <= 0 {
+		return
+	}
+	io.CopyN(r.hash, zeroReader{}, n)
+}
+
+// record добавляет прочитанный файл в манифест и возвращает добавленную запись
+func (r *fileRecorder) record(relPath string, info os.FileInfo) manifest.File {
+	if r == nil {
+		return manifest.File{}
+	}
+
+	file := manifest.File{
+		Path:    filepath.ToSlash(relPath),
+		Type:    manifest.TypeFile,
+		Size:    info.Size(),
+		Mode:    manifest.FormatMode(info.Mode()),
+		ModTime: info.ModTime(),
+		SHA256:  hex.EncodeToString(r.hash.Sum(nil)),
+	}
+	r.manifest.AddFile(file)
+	return file
+}
+
+// zeroReader возвращает бесконечный поток нулей
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,139 @@
+package compression
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"goback/manifest"
+	"goback/storage"
+)
+
+// manifestEntries возвращает записи манифеста в виде "путь тип"
+func manifestEntries(m *manifest.Manifest) []string {
+	var entries []string
+	for _, file := range m.Files {
+		entries = append(entries, file.Path+" "+file.Type)
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+func TestManifestDirectories(t *testing.T) {
+	source := t.TempDir()
+	if err := writeTestFiles(source, map[string]string{"css/site.css": "body {}", "cache/x.tmp": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(source, "uploads"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("css/site.css", filepath.Join(source, "style.css")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"css directory",
+		"css/site.css file",
+		"style.css symlink",
+		"uploads directory",
+	}
+
+	for _, compressionType := range []string{"tar", "tar.gz", "zip"} {
+		m := manifest.New("site", source)
+		opts := Options{
+			Manifest: m,
+			Exclude:  func(relPath string) bool { return relPath == "cache" },
+			Storage:  storage.NewLocal(t.TempDir()),
+		}
+		c, err := NewCompressor(compressionType, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Compress(source, "site"); err != nil {
+			t.Fatalf("%s: %v", compressionType, err)
+		}
+
+		if got := manifestEntries(m); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: manifest entries %v, want %v", compressionType, got, want)
+		}
+		for _, file := range m.Files {
+			if file.Path == "uploads" && file.Mode != "0750" {
+				t.Errorf("%s: uploads mode %s, want 0750", compressionType, file.Mode)
+			}
+		}
+		if !reflect.DeepEqual(m.Excluded, []string{"cache/"}) || len(m.Skipped) != 0 {
+			t.Errorf("%s: excluded %v, skipped %v", compressionType, m.Excluded, m.Skipped)
+		}
+	}
+}
+
+func TestManifestUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read files without read permission")
+	}
+
+	source := t.TempDir()
+	if err := writeTestFiles(source, map[string]string{
+		"index.html":   "<html>",
+		"secret.key":   "key",
+		"private/data": "data",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"secret.key", "private"} {
+		if err := os.Chmod(filepath.Join(source, path), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { os.Chmod(filepath.Join(source, "private"), 0755) })
+
+	m := manifest.New("site", source)
+	c := &TarCompressor{Options: Options{Manifest: m, Storage: storage.NewLocal(t.TempDir())}}
+	if err := c.Compress(source, "site.tar"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"index.html file", "private directory"}
+	if got := manifestEntries(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("manifest entries %v, want %v", got, want)
+	}
+	sort.Strings(m.Skipped)
+	if want := []string{"private/", "secret.key"}; !reflect.DeepEqual(m.Skipped, want) {
+		t.Errorf("skipped %v, want %v", m.Skipped, want)
+	}
+}
+
+func TestSkipUnreadable(t *testing.T) {
+	m := manifest.New("site", "/srv/site")
+
+	SkipUnreadable(m, "removed.txt", false, fmt.Errorf("open: %w", fs.ErrNotExist))
+	SkipUnreadable(m, "secret.key", false, fs.ErrPermission)
+	SkipUnreadable(m, filepath.Join("private", "nested"), true, fs.ErrPermission)
+	SkipUnreadable(nil, "other.key", false, fs.ErrPermission)
+
+	// Удаленный после обхода файл не считается недоступным
+	if want := []string{"secret.key", "private/nested/"}; !reflect.DeepEqual(m.Skipped, want) {
+		t.Errorf("skipped %v, want %v", m.Skipped, want)
+	}
+}
+
+func TestWalkSourceUnreadableRoot(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read directories without read permission")
+	}
+
+	source := t.TempDir()
+	if err := os.Chmod(source, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(source, 0755) })
+
+	c := &TarCompressor{Options: Options{Storage: storage.NewLocal(t.TempDir())}}
+	if err := c.Compress(source, "site.tar"); err == nil {
+		t.Error("unreadable source directory was archived without an error")
+	}
+}
@@ -0,0 +1,78 @@
+package compression
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+const (
+	// zipUnixExtraID - идентификатор поля Info-ZIP Unix ("ux") с uid и gid владельца
+	zipUnixExtraID = 0x7875
+	// maxLinkTarget - максимальная длина цели симлинка, хранящейся содержимым элемента zip
+	maxLinkTarget = 4096
+)
+
+// zipOwnerExtra возвращает поле "ux" с владельцем файла или nil, если платформа его не предоставляет
+func zipOwnerExtra(info os.FileInfo) []byte {
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return nil
+	}
+
+	extra := make([]byte, 4+11)
+	binary.LittleEndian.PutUint16(extra[0:2], zipUnixExtraID)
+	binary.LittleEndian.PutUint16(extra[2:4], 11)
+	extra[4] = 1 // версия поля
+	extra[5] = 4
+	binary.LittleEndian.PutUint32(extra[6:10], uint32(uid))
+	extra[10] = 4
+	binary.LittleEndian.PutUint32(extra[11:15], uint32(gid))
+	return extra
+}
+
+// zipOwner читает владельца из поля "ux" дополнительных данных элемента zip
+func zipOwner(extra []byte) *Owner {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			return nil
+		}
+		data := extra[4 : 4+size]
+		extra = extra[4+size:]
+
+		if id != zipUnixExtraID || len(data) < 2 || data[0] != 1 {
+			continue
+		}
+
+		uid, rest, ok := readZipID(data[1:])
+		if !ok {
+			return nil
+		}
+		gid, _, ok := readZipID(rest)
+		if !ok {
+			return nil
+		}
+		return &Owner{Uid: int(uid), Gid: int(gid)}
+	}
+
+	return nil
+}
+
+// readZipID читает число переменной длины (байт длины и значение в little-endian) из поля "ux"
+func readZipID(data []byte) (uint64, []byte, bool) {
+	if len(data) < 1 {
+		return 0, nil, false
+	}
+
+	size := int(data[0])
+	if size > 8 || len(data) < 1+size {
+		return 0, nil, false
+	}
+
+	var value uint64
+	for i := size - 1; i >= 0; i-- {
+		value = value<<8 | uint64(data[1+i])
+	}
+	return value, data[1+size:], true
+}
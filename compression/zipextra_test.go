@@ -0,0 +1,100 @@
+package compression
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestZipOwner(t *testing.T) {
+	ux := func(data ...byte) []byte {
+		extra := make([]byte, 4, 4+len(data))
+		binary.LittleEndian.PutUint16(extra[0:2], zipUnixExtraID)
+		binary.LittleEndian.PutUint16(extra[2:4], uint16(len(data)))
+		return append(extra, data...)
+	}
+	// Поле extended timestamp ("UT"), которое пишут другие архиваторы
+	timestamp := []byte{0x55, 0x54, 0x05, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		name  string
+		extra []byte
+		want  *Owner
+	}{
+		{name: "empty", extra: nil, want: nil},
+		{name: "4-byte ids", extra: ux(1, 4, 0xe8, 0x03, 0, 0, 4, 0xe9, 0x03, 0, 0), want: &Owner{Uid: 1000, Gid: 1001}},
+		{name: "short ids", extra: ux(1, 1, 0, 2, 0x21, 0x00), want: &Owner{Uid: 0, Gid: 33}},
+		{name: "after other field", extra: append(timestamp, ux(1, 1, 5, 1, 6)...), want: &Owner{Uid: 5, Gid: 6}},
+		{name: "other field only", extra: timestamp, want: nil},
+		{name: "unknown version", extra: ux(2, 1, 5, 1, 6), want: nil},
+		{name: "truncated id", extra: ux(1, 4, 0xe8, 0x03), want: nil},
+		{name: "truncated field", extra: ux(1, 1, 5, 1, 6)[:7], want: nil},
+		{name: "id too long", extra: ux(1, 9, 1, 2, 3, 4, 5, 6, 7, 8, 9, 1, 6), want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := zipOwner(tt.extra); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: zipOwner = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	source := t.TempDir()
+	if err := os.Mkdir(filepath.Join(source, "private"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "private", "script.sh"), []byte("#!/bin/sh\n"), 0754); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("private/script.sh", filepath.Join(source, "run")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(source, "private"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	b, destination := roundTrip(t, "zip", Options{}, ReadOptions{}, source, "site.zip")
+
+	target, err := os.Readlink(filepath.Join(destination, "run"))
+	if err != nil {
+		t.Fatalf("symlink not restored: %v", err)
+	}
+	if target != "private/script.sh" {
+		t.Errorf("symlink target = %q", target)
+	}
+
+	for name, want := range map[string]os.FileMode{"private": os.ModeDir | 0750, "private/script.sh": 0754} {
+		info, err := os.Lstat(filepath.Join(destination, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != want {
+			t.Errorf("%s mode = %v, want %v", name, info.Mode(), want)
+		}
+	}
+
+	// Владелец сохраняется в поле "ux", если платформа его предоставляет
+	info, err := os.Lstat(filepath.Join(source, "private", "script.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return
+	}
+
+	d := &ZipDecompressor{Storage: b}
+	err = d.Walk("site.zip", func(entry Entry, r io.Reader) error {
+		if entry.Owner == nil || entry.Owner.Uid != uid || entry.Owner.Gid != gid {
+			t.Errorf("%s owner = %+v, want %d:%d", entry.Name, entry.Owner, uid, gid)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,10 +1,20 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+
+	"goback/checksum"
+	"goback/compression"
+	"goback/encryption"
+	"goback/signature"
+	"goback/storage"
+	"goback/utils"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,27 +26,230 @@ type RetentionPolicy struct {
 	Yearly  int `yaml:"yearly"`
 }
 
+// DrillConfig описывает проверку восстановления: последний архив распаковывается
+// во временную директорию и проверяется командой и количеством файлов
+type DrillConfig struct {
+	Command     string `yaml:"command"`
+	MinFiles    int    `yaml:"min_files"`
+	AfterBackup bool   `yaml:"after_backup"`
+}
+
+// CompressionConfig - тип сжатия. В YAML задается строкой ("gzip", "zip", "tar", "tar.gz", "none")
+// или секцией для внешней программы сжатия:
+//
+//	compression: {type: filter, command: "zstd -T0 -19", extension: ".zst", decompress_command: "zstd -d"}
+type CompressionConfig struct {
+	Type              string `yaml:"type"`
+	Command           string `yaml:"command"`
+	Extension         string `yaml:"extension"`
+	DecompressCommand string `yaml:"decompress_command"`
+}
+
+func (c *CompressionConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*c = CompressionConfig{Type: value.Value}
+		return nil
+	}
+
+	type plain CompressionConfig
+	return value.Decode((*plain)(c))
+}
+
+// Filter возвращает описание внешней программы сжатия или nil, если тип не filter
+func (c CompressionConfig) Filter() *compression.Filter {
+	if !strings.EqualFold(c.Type, compression.CompressionFilter) {
+		return nil
+	}
+	return &compression.Filter{
+		Command:           c.Command,
+		Extension:         c.Extension,
+		DecompressCommand: c.DecompressCommand,
+	}
+}
+
+// IncompressibleConfig описывает уже сжатые файлы (изображения, видео, архивы), которые
+// сохраняются без повторного сжатия: в zip методом store, в tar.gz - при включенном tar_gz
+type IncompressibleConfig struct {
+	// Extensions - расширения таких файлов; если не указаны, используется встроенный список
+	Extensions []string `yaml:"extensions"`
+	// Sniff - определять уже сжатые файлы по энтропии начала файла
+	Sniff bool `yaml:"sniff"`
+	// TarGz - записывать такие файлы в tar.gz без сжатия
+	TarGz bool `yaml:"tar_gz"`
+}
+
+// StorePolicy возвращает правила сохранения без сжатия для компрессора
+func (c IncompressibleConfig) StorePolicy() compression.StorePolicy {
+	extensions := compression.DefaultStoreExtensions
+	if c.Extensions != nil {
+		extensions = make([]string, 0, len(c.Extensions))
+		for _, ext := range c.Extensions {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			extensions = append(extensions, ext)
+		}
+	}
+
+	return compression.StorePolicy{
+		Extensions: extensions,
+		Sniff:      c.Sniff,
+		TarGz:      c.TarGz,
+	}
+}
+
+// EncryptionConfig - шифрование архивов парольной фразой и/или открытыми ключами X25519.
+// Фраза читается из переменной окружения passphrase_env или из файла passphrase_file только
+// при шифровании и расшифровке. Для шифрования ключами на сервере нужны только открытые ключи
+// recipients, а файл закрытых ключей identity_file задается там, где архивы восстанавливаются
+type EncryptionConfig struct {
+	PassphraseEnv  string   `yaml:"passphrase_env"`
+	PassphraseFile string   `yaml:"passphrase_file"`
+	PublicKeys     []string `yaml:"recipients"`
+	IdentityFile   string   `yaml:"identity_file"`
+}
+
+// Enabled проверяет, шифруются ли новые архивы
+func (c EncryptionConfig) Enabled() bool {
+	return c.hasPassphrase() || len(c.PublicKeys) > 0
+}
+
+func (c EncryptionConfig) hasPassphrase() bool {
+	return c.PassphraseEnv != "" || c.PassphraseFile != ""
+}
+
+func (c EncryptionConfig) passphrase() encryption.Passphrase {
+	return encryption.Passphrase{Env: c.PassphraseEnv, File: c.PassphraseFile}
+}
+
+// Recipients возвращает получателей, для которых шифруются новые архивы
+func (c EncryptionConfig) Recipients() []encryption.Recipient {
+	var recipients []encryption.Recipient
+	if c.hasPassphrase() {
+		recipients = append(recipients, c.passphrase())
+	}
+
+	// Ключи проверены при загрузке конфигурации
+	for _, key := range c.PublicKeys {
+		if recipient, err := encryption.ParseRecipient(key); err == nil {
+			recipients = append(recipients, recipient)
+		}
+	}
+	return recipients
+}
+
+// Identities возвращает ключи расшифровки архивов. Файл закрытых ключей проверяется первым,
+// чтобы отсутствие парольной фразы не мешало восстановлению по ключу
+func (c EncryptionConfig) Identities() []encryption.Identity {
+	var identities []encryption.Identity
+	if c.IdentityFile != "" {
+		identities = append(identities, encryption.IdentityFile(c.IdentityFile))
+	}
+	if c.hasPassphrase() {
+		identities = append(identities, c.passphrase())
+	}
+	return identities
+}
+
+// SigningConfig - подпись архивов ключом Ed25519. На сервере задается key_file, по которому
+// подписывается каждый архив, а там, где архивы проверяются, достаточно открытых ключей public_keys
+type SigningConfig struct {
+	KeyFile    string   `yaml:"key_file"`
+	PublicKeys []string `yaml:"public_keys"`
+}
+
+// Enabled проверяет, задана ли подпись. В этом случае verify, restore, diff и drills требуют подписанные архивы
+func (c SigningConfig) Enabled() bool {
+	return c.KeyFile != "" || len(c.PublicKeys) > 0
+}
+
+// TrustedKeys возвращает ключи для проверки подписей. Без public_keys используется открытый ключ,
+// соответствующий key_file
+func (c SigningConfig) TrustedKeys() ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, value := range c.PublicKeys {
+		key, err := signature.ParsePublicKey(value)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 && c.KeyFile != "" {
+		key, err := signature.LoadPrivateKey(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.Public().(ed25519.PublicKey))
+	}
+	return keys, nil
+}
+
+// Verify проверяет подпись контрольных сумм архива доверенным ключом и соответствие архива им
+func (c SigningConfig) Verify(b storage.Backend, path string) error {
+	keys, err := c.TrustedKeys()
+	if err != nil {
+		return err
+	}
+
+	if err := signature.Verify(b, path, keys); err != nil {
+		return err
+	}
+
+	// Подпись покрывает только контрольные суммы, поэтому архив сверяется с ними,
+	// включая имя, иначе подписанные суммы можно приложить к подмененному архиву
+	return checksum.VerifyStrict(b, path)
+}
+
+// Режимы получения результата команды бэкапа
+const (
+	// CaptureFile - команда пишет дамп в output_file
+	CaptureFile = "file"
+	// CaptureStdout - stdout команды сразу передается в компрессор
+	CaptureStdout = "stdout"
+)
+
 type GlobalConfig struct {
-	BackupDir          string          `yaml:"backup_dir"`
-	Retention          RetentionPolicy `yaml:"retention"`
-	FilenameMask       string          `yaml:"filename_mask"`
-	DefaultCompression string          `yaml:"default_compression"`
-	PreHooks           []string        `yaml:"pre_hooks"`
-	PostHooks          []string        `yaml:"post_hooks"`
-	IncludeDir         string          `yaml:"include_dir"`
+	BackupDir          string               `yaml:"backup_dir"`
+	Retention          RetentionPolicy      `yaml:"retention"`
+	FilenameMask       string               `yaml:"filename_mask"`
+	DefaultCompression CompressionConfig    `yaml:"default_compression"`
+	CompressionLevel   string               `yaml:"compression_level"`
+	Incompressible     IncompressibleConfig `yaml:"incompressible"`
+	Encryption         EncryptionConfig     `yaml:"encryption"`
+	Signing            SigningConfig        `yaml:"signing"`
+	PreHooks           []string             `yaml:"pre_hooks"`
+	PostHooks          []string             `yaml:"post_hooks"`
+	IncludeDir         string               `yaml:"include_dir"`
+	Timezone           string               `yaml:"timezone"`
+
+	// IdentityFiles - файлы закрытых ключей, переданные в командной строке (--identity)
+	IdentityFiles []string `yaml:"-"`
+
+	location *time.Location
 }
 
 type BackupConfig struct {
-	Name            string           `yaml:"name"`
-	Subdirectory    string           `yaml:"subdirectory"`
-	SourceDir       string           `yaml:"source_dir"`
-	Command         string           `yaml:"command"`
-	OutputFile      string           `yaml:"output_file"`
-	Compression     string           `yaml:"compression"`
-	ExcludePatterns []string         `yaml:"exclude_patterns"`
-	Retention       *RetentionPolicy `yaml:"retention"`
-	PreHooks        []string         `yaml:"pre_hooks"`
-	PostHooks       []string         `yaml:"post_hooks"`
+	Name             string                `yaml:"name"`
+	Subdirectory     string                `yaml:"subdirectory"`
+	SourceDir        string                `yaml:"source_dir"`
+	Command          string                `yaml:"command"`
+	OutputFile       string                `yaml:"output_file"`
+	Capture          string                `yaml:"capture"`
+	RestoreCommand   string                `yaml:"restore_command"`
+	Compression      CompressionConfig     `yaml:"compression"`
+	CompressionLevel string                `yaml:"compression_level"`
+	Threads          int                   `yaml:"threads"`
+	SplitSize        string                `yaml:"split_size"`
+	Incompressible   *IncompressibleConfig `yaml:"incompressible"`
+	Encryption       *EncryptionConfig     `yaml:"encryption"`
+	ExcludePatterns  []string              `yaml:"exclude_patterns"`
+	Staging          bool                  `yaml:"staging"`
+	Retention        *RetentionPolicy      `yaml:"retention"`
+	PreHooks         []string              `yaml:"pre_hooks"`
+	PostHooks        []string              `yaml:"post_hooks"`
+	Drill            *DrillConfig          `yaml:"drill"`
 }
 
 type Config struct {
@@ -44,6 +257,90 @@ type Config struct {
 	Backups []BackupConfig `yaml:"backups"`
 }
 
+// Location возвращает часовой пояс для имен архивов и выбора бэкапа по времени
+func (g *GlobalConfig) Location() *time.Location {
+	if g.location == nil {
+		return time.Local
+	}
+	return g.location
+}
+
+// CompressionFor возвращает тип сжатия бэкапа, учитывая default_compression
+func (g *GlobalConfig) CompressionFor(backup *BackupConfig) CompressionConfig {
+	if backup.Compression.Type != "" {
+		return backup.Compression
+	}
+	return g.DefaultCompression
+}
+
+// minSplitSize - минимальный размер тома архива
+const minSplitSize = 1 << 20
+
+// SplitBytes возвращает размер тома архива в байтах или 0, если архив не разбивается на тома.
+// Значение проверяется при загрузке конфигурации
+func (b *BackupConfig) SplitBytes() int64 {
+	if b.SplitSize == "" {
+		return 0
+	}
+	size, err := utils.ParseSize(b.SplitSize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// CompressionLevelFor возвращает уровень сжатия бэкапа, учитывая глобальный уровень по умолчанию
+func (g *GlobalConfig) CompressionLevelFor(backup *BackupConfig) string {
+	if backup.CompressionLevel != "" {
+		return backup.CompressionLevel
+	}
+	return g.CompressionLevel
+}
+
+// IncompressibleFor возвращает настройки уже сжатых файлов бэкапа, учитывая глобальные настройки
+func (g *GlobalConfig) IncompressibleFor(backup *BackupConfig) IncompressibleConfig {
+	if backup.Incompressible != nil {
+		return *backup.Incompressible
+	}
+	return g.Incompressible
+}
+
+// EncryptionFor возвращает настройки шифрования бэкапа, учитывая глобальные настройки
+func (g *GlobalConfig) EncryptionFor(backup *BackupConfig) EncryptionConfig {
+	if backup.Encryption != nil {
+		return *backup.Encryption
+	}
+	return g.Encryption
+}
+
+// Storage возвращает хранилище архивов: директорию backup_dir
+func (g *GlobalConfig) Storage() storage.Backend {
+	return storage.NewLocal(g.BackupDir)
+}
+
+// ReadOptionsFor возвращает параметры чтения архивов бэкапа: фильтр сжатия, ключи расшифровки
+// и хранилище
+func (g *GlobalConfig) ReadOptionsFor(backup *BackupConfig) compression.ReadOptions {
+	var identities []encryption.Identity
+	for _, path := range g.IdentityFiles {
+		identities = append(identities, encryption.IdentityFile(path))
+	}
+
+	return compression.ReadOptions{
+		Filter:     g.CompressionFor(backup).Filter(),
+		Identities: append(identities, g.EncryptionFor(backup).Identities()...),
+		Storage:    g.Storage(),
+	}
+}
+
+// RetentionFor возвращает политику хранения бэкапа, учитывая глобальную политику по умолчанию
+func (g *GlobalConfig) RetentionFor(backup *BackupConfig) RetentionPolicy {
+	if backup.Retention != nil {
+		return *backup.Retention
+	}
+	return g.Retention
+}
+
 func LoadConfig(configPath string) (*Config, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
@@ -51,7 +348,7 @@ func LoadConfig(configPath string) (*Config, error) {
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := decodeYAML(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
@@ -97,7 +394,7 @@ func loadBackupsFromDir(dir string) ([]BackupConfig, error) {
 		}
 
 		var backup BackupConfig
-		if err := yaml.Unmarshal(data, &backup); err != nil {
+		if err := decodeYAML(data, &backup); err != nil {
 			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
 		}
 
@@ -116,8 +413,38 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("filename_mask is required")
 	}
 
-	if config.Global.DefaultCompression == "" {
-		config.Global.DefaultCompression = "none"
+	if config.Global.DefaultCompression.Type == "" {
+		config.Global.DefaultCompression.Type = "none"
+	}
+
+	if err := validateCompression(config.Global.DefaultCompression); err != nil {
+		return fmt.Errorf("default_compression: %w", err)
+	}
+
+	if _, err := compression.ParseLevel(config.Global.CompressionLevel); err != nil {
+		return fmt.Errorf("compression_level: %w", err)
+	}
+
+	if err := validateIncompressible(config.Global.Incompressible); err != nil {
+		return fmt.Errorf("incompressible: %w", err)
+	}
+
+	if err := validateEncryption(config.Global.Encryption); err != nil {
+		return fmt.Errorf("encryption: %w", err)
+	}
+
+	for i, key := range config.Global.Signing.PublicKeys {
+		if _, err := signature.ParsePublicKey(key); err != nil {
+			return fmt.Errorf("signing: public_keys[%d]: %w", i, err)
+		}
+	}
+
+	if config.Global.Timezone != "" {
+		location, err := time.LoadLocation(config.Global.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+		config.Global.location = location
 	}
 
 	for i, backup := range config.Backups {
@@ -129,9 +456,30 @@ func validateConfig(config *Config) error {
 			return fmt.Errorf("backup[%d]: subdirectory is required", i)
 		}
 
-		// Должен быть либо source_dir, либо (command + output_file)
+		// Должен быть либо source_dir, либо (command + output_file), либо command с capture: stdout
 		hasSourceDir := backup.SourceDir != ""
-		hasCommand := backup.Command != "" && backup.OutputFile != ""
+		var hasCommand bool
+
+		switch backup.Capture {
+		case "", CaptureFile:
+			hasCommand = backup.Command != "" && backup.OutputFile != ""
+		case CaptureStdout:
+			if backup.Command == "" {
+				return fmt.Errorf("backup[%d]: capture: stdout requires command", i)
+			}
+			if backup.OutputFile != "" {
+				return fmt.Errorf("backup[%d]: output_file cannot be used with capture: stdout", i)
+			}
+
+			// tar требует размер файла в заголовке до записи содержимого, поэтому поток в него не пишется
+			compressionType := config.Global.CompressionFor(&backup).Type
+			if c := strings.ToLower(compressionType); c == "tar" || c == "tar.gz" {
+				return fmt.Errorf("backup[%d]: capture: stdout does not support %s compression, use gzip, zip, filter or none", i, compressionType)
+			}
+			hasCommand = true
+		default:
+			return fmt.Errorf("backup[%d]: invalid capture %q (expected %q or %q)", i, backup.Capture, CaptureFile, CaptureStdout)
+		}
 
 		if !hasSourceDir && !hasCommand {
 			return fmt.Errorf("backup[%d]: must have either source_dir or (command + output_file)", i)
@@ -140,8 +488,110 @@ func validateConfig(config *Config) error {
 		if hasSourceDir && hasCommand {
 			return fmt.Errorf("backup[%d]: cannot have both source_dir and command", i)
 		}
+
+		if err := validateCompression(backup.Compression); err != nil {
+			return fmt.Errorf("backup[%d]: compression: %w", i, err)
+		}
+
+		if _, err := compression.ParseLevel(backup.CompressionLevel); err != nil {
+			return fmt.Errorf("backup[%d]: compression_level: %w", i, err)
+		}
+
+		if backup.Threads < 0 {
+			return fmt.Errorf("backup[%d]: threads must not be negative", i)
+		}
+
+		if backup.Incompressible != nil {
+			if err := validateIncompressible(*backup.Incompressible); err != nil {
+				return fmt.Errorf("backup[%d]: incompressible: %w", i, err)
+			}
+		}
+
+		if backup.Encryption != nil {
+			if err := validateEncryption(*backup.Encryption); err != nil {
+				return fmt.Errorf("backup[%d]: encryption: %w", i, err)
+			}
+		}
+
+		if backup.SplitSize != "" {
+			size, err := utils.ParseSize(backup.SplitSize)
+			if err != nil {
+				return fmt.Errorf("backup[%d]: split_size: %w", i, err)
+			}
+			if size < minSplitSize {
+				return fmt.Errorf("backup[%d]: split_size must be at least 1MiB", i)
+			}
+		}
+
+		if backup.RestoreCommand != "" && !hasCommand {
+			return fmt.Errorf("backup[%d]: restore_command is only supported for command backups", i)
+		}
+	}
+
+	return nil
+}
+
+func validateIncompressible(c IncompressibleConfig) error {
+	for _, ext := range c.Extensions {
+		if strings.Trim(strings.TrimSpace(ext), ".") == "" {
+			return fmt.Errorf("empty extension")
+		}
+	}
+	return nil
+}
+
+func validateEncryption(c EncryptionConfig) error {
+	if c.PassphraseEnv != "" && c.PassphraseFile != "" {
+		return fmt.Errorf("passphrase_env and passphrase_file are mutually exclusive")
 	}
 
+	for i, key := range c.PublicKeys {
+		if _, err := encryption.ParseRecipient(key); err != nil {
+			return fmt.Errorf("recipients[%d]: %w", i, err)
+		}
+	}
 	return nil
 }
 
+// extensionRe - допустимое расширение архива внешней программы сжатия
+var extensionRe = regexp.MustCompile(`^\.[A-Za-z0-9]+$`)
+
+// digitsRe - расширение только из цифр, как у томов архива
+var digitsRe = regexp.MustCompile(`^\.[0-9]+$`)
+
+func validateCompression(c CompressionConfig) error {
+	if !strings.EqualFold(c.Type, compression.CompressionFilter) {
+		if c.Command != "" || c.Extension != "" || c.DecompressCommand != "" {
+			return fmt.Errorf("command, extension and decompress_command are only supported for type filter")
+		}
+		return nil
+	}
+
+	if strings.TrimSpace(c.Command) == "" {
+		return fmt.Errorf("filter requires command")
+	}
+
+	if strings.TrimSpace(c.DecompressCommand) == "" {
+		return fmt.Errorf("filter requires decompress_command")
+	}
+
+	// Расширение не должно совпадать со встроенными форматами, иначе архив нельзя будет распознать
+	if !extensionRe.MatchString(c.Extension) {
+		return fmt.Errorf("filter requires extension like \".zst\", got %q", c.Extension)
+	}
+	switch strings.ToLower(c.Extension) {
+	case ".gz", ".zip", ".tar":
+		return fmt.Errorf("filter extension %s is reserved for built-in compression", c.Extension)
+	case encryption.Extension:
+		return fmt.Errorf("filter extension %s is reserved for encrypted archives", c.Extension)
+	}
+	if utils.IsSidecar(strings.ToLower(c.Extension)) {
+		return fmt.Errorf("filter extension %s is reserved for files stored next to archives", c.Extension)
+	}
+	// Числовые расширения (.001) занимают тома архива, разбитого на части
+	if digitsRe.MatchString(c.Extension) {
+		return fmt.Errorf("filter extension %s is reserved for split volumes", c.Extension)
+	}
+
+	return nil
+}
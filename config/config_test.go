@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCompression(t *testing.T) {
+	filter := func(ext string) CompressionConfig {
+		return CompressionConfig{Type: "filter", Command: "zstd", DecompressCommand: "zstd -d", Extension: ext}
+	}
+
+	tests := []struct {
+		name    string
+		config  CompressionConfig
+		wantErr string
+	}{
+		{name: "builtin", config: CompressionConfig{Type: "gzip"}},
+		{name: "filter", config: filter(".zst")},
+		{name: "builtin with command", config: CompressionConfig{Type: "gzip", Command: "zstd"}, wantErr: "only supported for type filter"},
+		{name: "no command", config: CompressionConfig{Type: "filter", DecompressCommand: "zstd -d", Extension: ".zst"}, wantErr: "requires command"},
+		{name: "no decompress command", config: CompressionConfig{Type: "filter", Command: "zstd", Extension: ".zst"}, wantErr: "requires decompress_command"},
+		{name: "no dot", config: filter("zst"), wantErr: "requires extension"},
+		{name: "nested", config: filter(".tar.zst"), wantErr: "requires extension"},
+		{name: "gzip", config: filter(".GZ"), wantErr: "reserved for built-in compression"},
+		{name: "encrypted", config: filter(".enc"), wantErr: "reserved for encrypted archives"},
+		{name: "checksum", config: filter(".sha256"), wantErr: "reserved for files stored next to archives"},
+		{name: "signature", config: filter(".sig"), wantErr: "reserved for files stored next to archives"},
+		{name: "volume", config: filter(".001"), wantErr: "reserved for split volumes"},
+		{name: "digits in name", config: filter(".lz4")},
+	}
+
+	for _, tt := range tests {
+		err := validateCompression(tt.config)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func loadTestConfig(t *testing.T, data string) (*Config, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(path)
+}
+
+const testGlobal = `
+global:
+  backup_dir: /var/backups
+  filename_mask: "%name%-%Y%m%d%H%M%S"
+`
+
+func TestLoadConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		backups string
+		wantErr string
+	}{
+		{name: "directory", backups: `
+  - name: site
+    subdirectory: site
+    source_dir: /var/www`},
+		{name: "command", backups: `
+  - name: db
+    subdirectory: db
+    command: pg_dump
+    output_file: /tmp/db.sql`},
+		{name: "stdout", backups: `
+  - name: db
+    subdirectory: db
+    command: pg_dump
+    capture: stdout
+    compression: gzip`},
+		{name: "no name", backups: `
+  - subdirectory: site
+    source_dir: /var/www`, wantErr: "backup[0]: name is required"},
+		{name: "no source", backups: `
+  - name: site
+    subdirectory: site`, wantErr: "must have either source_dir"},
+		{name: "both sources", backups: `
+  - name: site
+    subdirectory: site
+    source_dir: /var/www
+    command: pg_dump
+    output_file: /tmp/db.sql`, wantErr: "cannot have both"},
+		{name: "stdout tar", backups: `
+  - name: db
+    subdirectory: db
+    command: pg_dump
+    capture: stdout
+    compression: tar`, wantErr: "does not support tar compression"},
+		{name: "small split", backups: `
+  - name: site
+    subdirectory: site
+    source_dir: /var/www
+    split_size: 1KiB`, wantErr: "split_size must be at least 1MiB"},
+		{name: "restore command for directory", backups: `
+  - name: site
+    subdirectory: site
+    source_dir: /var/www
+    restore_command: cat`, wantErr: "restore_command is only supported for command backups"},
+		{name: "filter volume extension", backups: `
+  - name: site
+    subdirectory: site
+    source_dir: /var/www
+    compression:
+      type: filter
+      command: zstd
+      decompress_command: zstd -d
+      extension: ".002"`, wantErr: "backup[0]: compression: filter extension .002 is reserved for split volumes"},
+		{name: "compression level", backups: `
+  - name: site
+    subdirectory: site
+    source_dir: /var/www
+    compression_level: 10`, wantErr: "backup[0]: compression_level: invalid compression level \"10\""},
+	}
+
+	for _, tt := range tests {
+		_, err := loadTestConfig(t, testGlobal+"backups:"+tt.backups+"\n")
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfigGlobalValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "no backup_dir", config: "global:\n  filename_mask: x\n", wantErr: "backup_dir is required"},
+		{name: "no filename_mask", config: "global:\n  backup_dir: /b\n", wantErr: "filename_mask is required"},
+		{name: "timezone", config: testGlobal + "  timezone: Mars/Olympus\n", wantErr: "invalid timezone"},
+		{name: "recipient", config: testGlobal + "  encryption:\n    recipients: [\"goback-pub-bad\"]\n", wantErr: "encryption"},
+		{name: "compression level", config: testGlobal + "  compression_level: max\n", wantErr: "compression_level: invalid compression level"},
+		{name: "signing key", config: testGlobal + "  signing:\n    public_keys: [\"bad\"]\n", wantErr: "signing: public_keys[0]"},
+	}
+
+	for _, tt := range tests {
+		_, err := loadTestConfig(t, tt.config)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := loadTestConfig(t, testGlobal+"  timezone: Europe/Moscow\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Global.DefaultCompression.Type != "none" {
+		t.Errorf("default compression = %q, want none", cfg.Global.DefaultCompression.Type)
+	}
+	if cfg.Global.Location().String() != "Europe/Moscow" {
+		t.Errorf("location = %s, want Europe/Moscow", cfg.Global.Location())
+	}
+}
+
+func TestCompressionLevelFor(t *testing.T) {
+	cfg, err := loadTestConfig(t, testGlobal+`  compression_level: fastest
+backups:
+  - name: site
+    subdirectory: site
+    source_dir: /var/www
+  - name: media
+    subdirectory: media
+    source_dir: /srv/media
+    compression_level: store
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.Global.CompressionLevelFor(&cfg.Backups[0]); got != "fastest" {
+		t.Errorf("site level = %q, want global fastest", got)
+	}
+	if got := cfg.Global.CompressionLevelFor(&cfg.Backups[1]); got != "store" {
+		t.Errorf("media level = %q, want store", got)
+	}
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"goback/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// filePrefix - префикс ссылки на файл с секретом: ${file:/run/secrets/db_pass}
+const filePrefix = "file:"
+
+// secretPrefix - префикс ссылки на переменную окружения с секретом: ${secret:DB_PASS}
+const secretPrefix = "secret:"
+
+// varNameRe - допустимое имя переменной окружения
+var varNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shellKeys - ключи с командами, которые выполняются через sh -c. Подставленные в них значения
+// экранируются, чтобы пароль с $, пробелами, ; или ` передавался одним аргументом и не менял команду
+var shellKeys = map[string]bool{
+	"command":            true,
+	"restore_command":    true,
+	"decompress_command": true,
+}
+
+// decodeYAML разбирает YAML в out, раскрывая ссылки ${...} в строковых значениях
+func decodeYAML(data []byte, out interface{}) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	// Пустой документ
+	if root.Kind == 0 {
+		return nil
+	}
+
+	if err := interpolateNode(&root, false); err != nil {
+		return err
+	}
+
+	return root.Decode(out)
+}
+
+// interpolateNode раскрывает ссылки во всех строковых значениях дерева.
+// Ключи и значения других типов не изменяются, псевдонимы указывают на уже обработанные узлы.
+// shell - узел является значением одного из shellKeys
+func interpolateNode(node *yaml.Node, shell bool) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := interpolateNode(child, shell); err != nil {
+				return err
+			}
+		}
+
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i], shellKeys[node.Content[i-1].Value]); err != nil {
+				return err
+			}
+		}
+
+	case yaml.ScalarNode:
+		if node.ShortTag() != "!!str" || !strings.Contains(node.Value, "$") {
+			return nil
+		}
+
+		value, err := interpolate(node.Value, shell)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+	}
+
+	return nil
+}
+
+// interpolate раскрывает в строке ссылки:
+//
+//	${VAR}          - значение переменной окружения, неопределенная переменная - ошибка
+//	${VAR:-default} - значение переменной или default, если она не задана или пуста;
+//	                  default может сам содержать ссылки, например ${DB_HOST:-${HOSTNAME}}
+//	${file:path}    - содержимое файла без завершающего перевода строки, значение маскируется в выводе
+//	${secret:VAR}   - значение переменной окружения, которое маскируется в выводе
+//	$${             - символы ${ без подстановки, например для переменных shell в командах
+//
+// $ без фигурных скобок остается как есть, поэтому $VAR в командах по-прежнему раскрывает shell.
+// При shell значения экранируются с учетом кавычек, внутри которых стоит ссылка
+func interpolate(s string, shell bool) (string, error) {
+	var result strings.Builder
+	var quoting shellQuoting
+
+	write := func(text string) {
+		result.WriteString(text)
+		quoting.scan(text)
+	}
+
+	for {
+		start := strings.Index(s, "$")
+		if start < 0 || start == len(s)-1 {
+			write(s)
+			return result.String(), nil
+		}
+
+		write(s[:start])
+		rest := s[start+1:]
+
+		switch {
+		case strings.HasPrefix(rest, "${"):
+			write("${")
+			s = rest[2:]
+			continue
+		case !strings.HasPrefix(rest, "{"):
+			write("$")
+			s = rest
+			continue
+		}
+
+		end := closingBrace(rest)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", s)
+		}
+
+		ref := rest[1:end]
+		value, err := resolveReference(ref)
+		if err != nil {
+			return "", err
+		}
+
+		if shell {
+			if quoting.escaped {
+				return "", fmt.Errorf("reference ${%s} follows a backslash in a shell command", ref)
+			}
+			quoted := quoting.quote(value)
+			// Экранированный секрет выглядит в команде иначе и тоже должен скрываться
+			if quoted != value && utils.Mask(value) != value {
+				utils.AddSecret(quoted)
+			}
+			value = quoted
+		}
+		result.WriteString(value)
+		s = rest[end+1:]
+	}
+}
+
+// shellQuoting отслеживает, внутри каких кавычек sh находится очередной символ команды
+type shellQuoting struct {
+	// open - открытая кавычка ' или ", 0 вне кавычек
+	open byte
+	// escaped - предыдущий символ - \ вне одинарных кавычек
+	escaped bool
+}
+
+// scan обновляет состояние по тексту команды
+func (q *shellQuoting) scan(text string) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case q.escaped:
+			q.escaped = false
+		case q.open == '\'':
+			if c == '\'' {
+				q.open = 0
+			}
+		case c == '\\':
+			q.escaped = true
+		case q.open == '"':
+			if c == '"' {
+				q.open = 0
+			}
+		case c == '\'' || c == '"':
+			q.open = c
+		}
+	}
+}
+
+// quote экранирует значение так, чтобы sh прочитал его буквально в текущем положении
+func (q *shellQuoting) quote(value string) string {
+	switch q.open {
+	case '\'':
+		// Одинарная кавычка закрывает строку, добавляется экранированной и открывает строку заново
+		return strings.ReplaceAll(value, "'", `'\''`)
+	case '"':
+		var sb strings.Builder
+		for i := 0; i < len(value); i++ {
+			switch value[i] {
+			case '\\', '$', '`', '"':
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(value[i])
+		}
+		return sb.String()
+	default:
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	}
+}
+
+// closingBrace возвращает индекс фигурной скобки, закрывающей открытую в s[0], с учетом вложенных ссылок
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// resolveReference возвращает значение ссылки без ${ и }
+func resolveReference(ref string) (string, error) {
+	if strings.HasPrefix(ref, filePrefix) {
+		path := strings.TrimPrefix(ref, filePrefix)
+		if path == "" {
+			return "", fmt.Errorf("empty file path in ${%s}", ref)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+
+		secret := strings.TrimRight(string(data), "\r\n")
+		utils.AddSecret(secret)
+		return secret, nil
+	}
+
+	if strings.HasPrefix(ref, secretPrefix) {
+		name := strings.TrimPrefix(ref, secretPrefix)
+		if !varNameRe.MatchString(name) {
+			return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+		}
+
+		secret, defined := os.LookupEnv(name)
+		if !defined {
+			return "", fmt.Errorf("undefined variable %s", name)
+		}
+		utils.AddSecret(secret)
+		return secret, nil
+	}
+
+	name, defaultValue, hasDefault := strings.Cut(ref, ":-")
+	if !varNameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+	}
+
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+
+	// Значение по умолчанию раскрывается, только если оно используется
+	if hasDefault {
+		return interpolate(defaultValue, false)
+	}
+
+	if _, defined := os.LookupEnv(name); defined {
+		return "", nil
+	}
+
+	return "", fmt.Errorf("undefined variable %s (write $${%s} to leave it to the shell)", name, name)
+}
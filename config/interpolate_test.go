@@ -0,0 +1,202 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goback/utils"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("GB_TEST_USER", "alice")
+	t.Setenv("GB_TEST_EMPTY", "")
+	os.Unsetenv("GB_TEST_UNSET")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${GB_TEST_USER}", want: "alice"},
+		{in: "-u ${GB_TEST_USER} -h db", want: "-u alice -h db"},
+		{in: "${GB_TEST_EMPTY}", want: ""},
+		{in: "${GB_TEST_UNSET:-fallback}", want: "fallback"},
+		{in: "${GB_TEST_EMPTY:-fallback}", want: "fallback"},
+		{in: "${GB_TEST_USER:-fallback}", want: "alice"},
+		{in: "${GB_TEST_UNSET:-${GB_TEST_USER}}", want: "alice"},
+		{in: "${GB_TEST_UNSET:-${GB_TEST_UNSET:-deep}}x", want: "deepx"},
+		// Неиспользуемое значение по умолчанию не раскрывается
+		{in: "${GB_TEST_USER:-${GB_TEST_UNSET}}", want: "alice"},
+		{in: "${file:" + secretFile + "}", want: "s3cret"},
+		{in: "${secret:GB_TEST_USER}", want: "alice"},
+		{in: "${secret:GB_TEST_EMPTY}", want: ""},
+		{in: "$${HOME}", want: "${HOME}"},
+		{in: "$$", want: "$$"},
+		{in: "$HOME and $", want: "$HOME and $"},
+		{in: "${GB_TEST_UNSET}", wantErr: "undefined variable GB_TEST_UNSET"},
+		{in: "${GB_TEST_USER", wantErr: "unterminated reference"},
+		{in: "${1BAD}", wantErr: "invalid variable reference"},
+		{in: "${file:}", wantErr: "empty file path"},
+		{in: "${secret:GB_TEST_UNSET}", wantErr: "undefined variable GB_TEST_UNSET"},
+		{in: "${secret:GB_TEST_UNSET:-x}", wantErr: "invalid variable reference"},
+		{in: "${file:" + secretFile + ".missing}", wantErr: "failed to read secret file"},
+	}
+
+	for _, tt := range tests {
+		got, err := interpolate(tt.in, false)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("interpolate(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("interpolate(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("interpolate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolateShell(t *testing.T) {
+	value := `pa ss'w"o$rd;` + "`id`\\ \\"
+	t.Setenv("GB_TEST_PASS", value)
+	t.Setenv("GB_TEST_EMPTY", "")
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: `printf '%s|' -p${GB_TEST_PASS}`, want: "-p" + value + "|"},
+		{command: `printf '%s|' "-p${GB_TEST_PASS}" x`, want: "-p" + value + "|x|"},
+		{command: `printf '%s|' '-p${GB_TEST_PASS}' x`, want: "-p" + value + "|x|"},
+		{command: `printf '%s|' "it's" ${GB_TEST_PASS} 'say "hi"'`, want: "it's|" + value + `|say "hi"|`},
+		{command: `printf '%s|' \" ${GB_TEST_PASS}`, want: `"|` + value + "|"},
+		{command: `printf '%s|' ${GB_TEST_EMPTY} x`, want: "|x|"},
+		{command: `printf '%s|' ${GB_TEST_UNSET:-${GB_TEST_PASS}}`, want: value + "|"},
+		{command: `printf '%s|' $${HOME:+set}`, want: "set|"},
+	}
+
+	for _, tt := range tests {
+		command, err := interpolate(tt.command, true)
+		if err != nil {
+			t.Errorf("interpolate(%q) error: %v", tt.command, err)
+			continue
+		}
+
+		output, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			t.Errorf("sh -c %q: %v", command, err)
+			continue
+		}
+		if string(output) != tt.want {
+			t.Errorf("sh -c %q printed %q, want %q", command, output, tt.want)
+		}
+	}
+
+	if _, err := interpolate(`echo \${GB_TEST_PASS}`, true); err == nil {
+		t.Error("expected error for reference after a backslash")
+	}
+}
+
+func TestDecodeYAMLQuotesShellCommands(t *testing.T) {
+	t.Setenv("GB_TEST_NAME", "my db")
+
+	data := []byte(`
+command: "dump ${GB_TEST_NAME}"
+restore_command: "load ${GB_TEST_NAME}"
+compression: {type: filter, command: "zstd ${GB_TEST_NAME}", decompress_command: "zstd -d ${GB_TEST_NAME}"}
+drill: {command: "check ${GB_TEST_NAME}"}
+pre_hooks: ["echo ${GB_TEST_NAME}"]
+output_file: "${GB_TEST_NAME}.sql"
+`)
+
+	var out map[string]interface{}
+	if err := decodeYAML(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	compression := out["compression"].(map[string]interface{})
+	drill := out["drill"].(map[string]interface{})
+	got := []interface{}{
+		out["command"], out["restore_command"], compression["command"], compression["decompress_command"],
+		drill["command"], out["pre_hooks"].([]interface{})[0], out["output_file"],
+	}
+	want := []interface{}{
+		"dump 'my db'", "load 'my db'", "zstd 'my db'", "zstd -d 'my db'",
+		"check 'my db'", "echo my db", "my db.sql",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("value %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecodeYAMLMasksSecrets(t *testing.T) {
+	t.Setenv("GB_TEST_TOKEN", "token-from-env-1234")
+	t.Setenv("GB_TEST_NAME", "name-not-secret-5678")
+	t.Setenv("GB_TEST_SHORT", "abc")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("password-from-file-9012\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`
+command: "dump -p ${file:` + secretFile + `} -t ${secret:GB_TEST_TOKEN} -s ${secret:GB_TEST_SHORT} ${GB_TEST_NAME}"
+output_file: "${GB_TEST_NAME}.sql"
+`)
+
+	var out struct {
+		Command    string `yaml:"command"`
+		OutputFile string `yaml:"output_file"`
+	}
+	if err := decodeYAML(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Command != "dump -p 'password-from-file-9012' -t 'token-from-env-1234' -s 'abc' 'name-not-secret-5678'" {
+		t.Fatalf("command = %q", out.Command)
+	}
+
+	// Маскируются только секреты; обычные переменные и слишком короткие значения остаются в выводе
+	want := "dump -p ****** -t ****** -s 'abc' 'name-not-secret-5678'"
+	if masked := utils.Mask(out.Command); masked != want {
+		t.Errorf("masked command = %q, want %q", masked, want)
+	}
+	if masked := utils.Mask(out.OutputFile); masked != out.OutputFile {
+		t.Errorf("path value masked: %s", masked)
+	}
+
+	// Секрет с кавычкой изменяется при экранировании, но скрывается и в команде
+	t.Setenv("GB_TEST_QUOTED", "it's-a-secret")
+	command, err := interpolate("login -p${secret:GB_TEST_QUOTED}", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if masked := utils.Mask(command); masked != "login -p******" {
+		t.Errorf("masked command = %q", masked)
+	}
+}
+
+func TestDecodeYAMLErrorLine(t *testing.T) {
+	os.Unsetenv("GB_TEST_UNSET")
+
+	var out map[string]string
+	err := decodeYAML([]byte("a: ok\nb: ${GB_TEST_UNSET}\n"), &out)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("error = %v, want line 2", err)
+	}
+}
@@ -0,0 +1,352 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Extension - расширение зашифрованного архива, добавляется после расширения сжатия
+const Extension = ".enc"
+
+const (
+	// magic начинает заголовок зашифрованного файла и задает версию формата
+	magic = "GOBACKE1"
+	// chunkSizeLog - двоичный логарифм размера блока открытых данных (64 КБ)
+	chunkSizeLog = 16
+	// fileKeySize - размер случайного ключа файла (AES-256)
+	fileKeySize = 32
+	// maxStanzas - ограничение количества получателей в заголовке
+	maxStanzas = 255
+)
+
+// ErrNoIdentity возвращается, если ни один из ключей расшифровки не подходит к файлу
+var ErrNoIdentity = errors.New("no matching key to decrypt the archive")
+
+// Stanza - запись заголовка, в которой ключ файла зашифрован для одного получателя
+type Stanza struct {
+	Type byte
+	Body []byte
+}
+
+// Recipient шифрует ключ файла для одного получателя
+type Recipient interface {
+	Wrap(fileKey []byte) (Stanza, error)
+}
+
+// Identity расшифровывает ключ файла из подходящей записи заголовка.
+// Если подходящей записи нет, возвращается ErrNoIdentity
+type Identity interface {
+	Unwrap(stanzas []Stanza) ([]byte, error)
+}
+
+// Формат файла:
+//
+//	magic "GOBACKE1" | размер блока (log2, 1 байт) | количество записей (1 байт)
+//	записи: тип (1 байт) | длина (2 байта, big-endian) | данные
+//	блоки: AES-256-GCM(блок открытых данных), nonce - номер блока (11 байт) и признак последнего блока
+//
+// Заголовок целиком передается как дополнительные данные каждого блока, поэтому его изменение,
+// перестановка, удаление блоков и обрезка файла обнаруживаются при расшифровке
+
+// NewWriter возвращает поток, который шифрует записанные данные для recipients и пишет их в w.
+// Close дописывает последний блок, но не закрывает w
+func NewWriter(w io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no encryption recipients")
+	}
+	if len(recipients) > maxStanzas {
+		return nil, fmt.Errorf("too many encryption recipients: %d", len(recipients))
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, fmt.Errorf("failed to generate file key: %w", err)
+	}
+
+	header := []byte(magic)
+	header = append(header, chunkSizeLog, byte(len(recipients)))
+	for _, recipient := range recipients {
+		stanza, err := recipient.Wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(stanza.Body) > 0xffff {
+			return nil, fmt.Errorf("encryption header entry is too large")
+		}
+		header = append(header, stanza.Type)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(stanza.Body)))
+		header = append(header, stanza.Body...)
+	}
+
+	aead, err := newAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &writer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, 1<<chunkSizeLog),
+	}, nil
+}
+
+type writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	out    []byte
+	chunk  uint64
+	closed bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("encryption: write to closed writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// Полный блок шифруется только при поступлении следующих данных:
+		// последним должен остаться блок, который будет записан в Close
+		if len(w.buf) == cap(w.buf) {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close шифрует последний блок (возможно, пустой) с признаком конца потока
+func (w *writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+func (w *writer) seal(last bool) error {
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.chunk, last), w.buf, w.header)
+	w.chunk++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(w.out)
+	return err
+}
+
+// Reader расшифровывает файл с произвольным доступом: блоки проверяются и расшифровываются
+// по мере чтения. Reader нельзя использовать из нескольких горутин одновременно
+type Reader struct {
+	r          io.ReaderAt
+	aead       cipher.AEAD
+	header     []byte
+	chunkSize  int64
+	dataOffset int64
+	chunks     int64
+	size       int64
+
+	// Последний расшифрованный блок
+	cached int64
+	plain  []byte
+	sealed []byte
+
+	pos int64
+}
+
+// NewReader читает заголовок зашифрованного файла размером size и подбирает ключ файла среди identities
+func NewReader(r io.ReaderAt, size int64, identities ...Identity) (*Reader, error) {
+	header, stanzas, err := readHeader(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+
+	var fileKey []byte
+	// Если ни один ключ не подошел, возвращается первая ошибка с подробностями, например о неверной парольной фразе
+	noMatch := ErrNoIdentity
+	for _, identity := range identities {
+		fileKey, err = identity.Unwrap(stanzas)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrNoIdentity) {
+			return nil, err
+		}
+		if noMatch == ErrNoIdentity {
+			noMatch = err
+		}
+	}
+	if fileKey == nil {
+		return nil, noMatch
+	}
+
+	aead, err := newAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := int64(1) << header[len(magic)]
+	sealedSize := chunkSize + int64(aead.Overhead())
+
+	// Последний блок есть всегда, даже пустой, поэтому количество блоков однозначно
+	// определяется размером зашифрованных данных
+	payload := size - int64(len(header))
+	chunks := (payload + sealedSize - 1) / sealedSize
+	if chunks == 0 || payload-(chunks-1)*sealedSize < int64(aead.Overhead()) {
+		return nil, fmt.Errorf("encrypted archive is truncated")
+	}
+
+	return &Reader{
+		r:          r,
+		aead:       aead,
+		header:     header,
+		chunkSize:  chunkSize,
+		dataOffset: int64(len(header)),
+		chunks:     chunks,
+		size:       payload - chunks*int64(aead.Overhead()),
+		cached:     -1,
+	}, nil
+}
+
+// Size возвращает размер расшифрованных данных
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("encryption: negative offset")
+	}
+
+	total := 0
+	for len(p) > 0 && off < r.size {
+		chunk := off / r.chunkSize
+		plain, err := r.open(chunk)
+		if err != nil {
+			return total, err
+		}
+
+		n := copy(p, plain[off-chunk*r.chunkSize:])
+		total += n
+		off += int64(n)
+		p = p[n:]
+	}
+
+	if len(p) > 0 {
+		return total, io.EOF
+	}
+	return total, nil
+}
+
+// open читает и расшифровывает блок с номером chunk
+func (r *Reader) open(chunk int64) ([]byte, error) {
+	if chunk == r.cached {
+		return r.plain, nil
+	}
+
+	sealedSize := r.chunkSize + int64(r.aead.Overhead())
+	offset := r.dataOffset + chunk*sealedSize
+	length := sealedSize
+	last := chunk == r.chunks-1
+	if last {
+		length = r.dataOffset + r.size + r.chunks*int64(r.aead.Overhead()) - offset
+	}
+
+	if int64(cap(r.sealed)) < length {
+		r.sealed = make([]byte, length)
+	}
+	sealed := r.sealed[:length]
+	if _, err := r.r.ReadAt(sealed, offset); err != nil {
+		return nil, fmt.Errorf("failed to read encrypted archive: %w", err)
+	}
+
+	plain, err := r.aead.Open(r.plain[:0], chunkNonce(uint64(chunk), last), sealed, r.header)
+	if err != nil {
+		r.cached = -1
+		return nil, fmt.Errorf("encrypted archive is corrupted or was modified (chunk %d)", chunk)
+	}
+	if !last && int64(len(plain)) != r.chunkSize {
+		return nil, fmt.Errorf("invalid encrypted chunk %d", chunk)
+	}
+
+	r.plain = plain
+	r.cached = chunk
+	return plain, nil
+}
+
+// readHeader читает заголовок и возвращает его байты и записи с ключом файла
+func readHeader(r io.Reader) ([]byte, []Stanza, error) {
+	fixed := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	if !bytes.Equal(fixed[:len(magic)], []byte(magic)) {
+		return nil, nil, fmt.Errorf("not an encrypted goback archive")
+	}
+
+	if chunkLog := fixed[len(magic)]; chunkLog < 10 || chunkLog > 24 {
+		return nil, nil, fmt.Errorf("invalid encryption header: chunk size 2^%d", chunkLog)
+	}
+
+	header := fixed
+	stanzas := make([]Stanza, 0, fixed[len(magic)+1])
+	for i := 0; i < int(fixed[len(magic)+1]); i++ {
+		prefix := make([]byte, 3)
+		if _, err := io.ReadFull(r, prefix); err != nil {
+			return nil, nil, fmt.Errorf("failed to read encryption header: %w", err)
+		}
+
+		body := make([]byte, binary.BigEndian.Uint16(prefix[1:]))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, nil, fmt.Errorf("failed to read encryption header: %w", err)
+		}
+
+		header = append(append(header, prefix...), body...)
+		stanzas = append(stanzas, Stanza{Type: prefix[0], Body: body})
+	}
+
+	return header, stanzas, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce возвращает nonce блока: номер блока и признак последнего блока
+func chunkNonce(chunk uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], chunk)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
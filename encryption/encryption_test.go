@@ -0,0 +1,223 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const chunkSize = 1 << chunkSizeLog
+
+// plainKey - тестовый получатель, который хранит ключ файла в заголовке открыто,
+// чтобы проверять формат файла без затрат на scrypt
+type plainKey struct{}
+
+const stanzaPlain = 0xfe
+
+func (plainKey) Wrap(fileKey []byte) (Stanza, error) {
+	return Stanza{Type: stanzaPlain, Body: fileKey}, nil
+}
+
+func (plainKey) Unwrap(stanzas []Stanza) ([]byte, error) {
+	for _, stanza := range stanzas {
+		if stanza.Type == stanzaPlain {
+			return stanza.Body, nil
+		}
+	}
+	return nil, ErrNoIdentity
+}
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func encrypt(t *testing.T, data []byte, recipients ...Recipient) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	w, err := NewWriter(&out, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Запись кусками, не совпадающими с границами блоков
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), 10000)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func decrypt(encrypted []byte, identities ...Identity) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(encrypted), int64(len(encrypted)), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 5} {
+		data := randomData(size)
+		encrypted := encrypt(t, data, plainKey{})
+
+		r, err := NewReader(bytes.NewReader(encrypted), int64(len(encrypted)), plainKey{})
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if r.Size() != int64(size) {
+			t.Errorf("size %d: Size() = %d", size, r.Size())
+		}
+
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("size %d: data mismatch after round trip", size)
+		}
+	}
+}
+
+func TestReaderReadAt(t *testing.T) {
+	data := randomData(3*chunkSize + 5)
+	encrypted := encrypt(t, data, plainKey{})
+
+	r, err := NewReader(bytes.NewReader(encrypted), int64(len(encrypted)), plainKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ off, length int }{
+		{0, 10}, {chunkSize - 3, 6}, {chunkSize, chunkSize}, {10, 2*chunkSize + 100}, {3 * chunkSize, 5},
+	} {
+		buf := make([]byte, tt.length)
+		if _, err := r.ReadAt(buf, int64(tt.off)); err != nil {
+			t.Fatalf("ReadAt(%d, %d): %v", tt.off, tt.length, err)
+		}
+		if !bytes.Equal(buf, data[tt.off:tt.off+tt.length]) {
+			t.Errorf("ReadAt(%d, %d) returned wrong data", tt.off, tt.length)
+		}
+	}
+
+	if n, err := r.ReadAt(make([]byte, 10), int64(len(data)-2)); n != 2 || err != io.EOF {
+		t.Errorf("ReadAt past end = %d, %v; want 2, EOF", n, err)
+	}
+}
+
+func TestTamperDetection(t *testing.T) {
+	data := randomData(2 * chunkSize)
+	encrypted := encrypt(t, data, plainKey{})
+	headerSize := len(magic) + 2 + 3 + fileKeySize
+	sealedSize := chunkSize + 16
+
+	modify := map[string]func([]byte) []byte{
+		"truncated by one byte": func(b []byte) []byte { return b[:len(b)-1] },
+		// Данные кратны размеру блока, поэтому последний блок пустой; без него файл выглядит целым
+		"last chunk removed": func(b []byte) []byte { return b[:len(b)-16] },
+		"truncated at chunk": func(b []byte) []byte { return b[:headerSize+sealedSize] },
+		"data modified": func(b []byte) []byte {
+			b[headerSize+100] ^= 1
+			return b
+		},
+		"chunk size modified": func(b []byte) []byte {
+			b[len(magic)]++
+			return b
+		},
+		"chunks swapped": func(b []byte) []byte {
+			first := append([]byte(nil), b[headerSize:headerSize+sealedSize]...)
+			copy(b[headerSize:], b[headerSize+sealedSize:headerSize+2*sealedSize])
+			copy(b[headerSize+sealedSize:], first)
+			return b
+		},
+		"extended": func(b []byte) []byte { return append(b, make([]byte, 16)...) },
+	}
+
+	for name, fn := range modify {
+		tampered := fn(append([]byte(nil), encrypted...))
+		if got, err := decrypt(tampered, plainKey{}); err == nil {
+			t.Errorf("%s: decrypted %d bytes without error", name, len(got))
+		}
+	}
+
+	if _, err := decrypt(encrypted[:len(magic)+1], plainKey{}); err == nil {
+		t.Error("truncated header accepted")
+	}
+	if _, err := decrypt([]byte("not an encrypted archive"), plainKey{}); err == nil {
+		t.Error("plain data accepted")
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	t.Setenv("GOBACK_TEST_PASS", "correct horse")
+	file := filepath.Join(t.TempDir(), "pass")
+	if err := os.WriteFile(file, []byte("correct horse\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	data := randomData(chunkSize + 1)
+	encrypted := encrypt(t, data, Passphrase{Env: "GOBACK_TEST_PASS"})
+
+	// Парольная фраза из файла без перевода строки совпадает с переменной окружения
+	got, err := decrypt(encrypted, Passphrase{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data mismatch after round trip")
+	}
+
+	t.Setenv("GOBACK_TEST_WRONG", "wrong horse")
+	_, err = decrypt(encrypted, Passphrase{Env: "GOBACK_TEST_WRONG"})
+	if !errors.Is(err, ErrNoIdentity) || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("wrong passphrase: %v, want wrong passphrase error matching ErrNoIdentity", err)
+	}
+
+	// Неверная парольная фраза не мешает попробовать остальные ключи
+	both := encrypt(t, data, Passphrase{Env: "GOBACK_TEST_PASS"}, plainKey{})
+	if _, err := decrypt(both, Passphrase{Env: "GOBACK_TEST_WRONG"}, plainKey{}); err != nil {
+		t.Errorf("key after wrong passphrase: %v", err)
+	}
+
+	if _, err := decrypt(encrypted, plainKey{}); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("identity without matching entry: %v, want ErrNoIdentity", err)
+	}
+
+	if _, err := NewWriter(io.Discard, Passphrase{Env: "GOBACK_TEST_UNSET"}); err == nil {
+		t.Error("unset passphrase variable accepted")
+	}
+}
+
+func TestPassphraseLimits(t *testing.T) {
+	t.Setenv("GOBACK_TEST_PASS", "correct horse")
+	passphrase := Passphrase{Env: "GOBACK_TEST_PASS"}
+
+	// Наибольшие допустимые параметры требуют не больше 1 ГиБ памяти
+	if memory := 128 * scryptMaxR << scryptMaxLogN; memory > 1<<30 {
+		t.Errorf("scrypt limits allow %d MiB", memory>>20)
+	}
+
+	for _, params := range [][]byte{
+		{scryptMaxLogN + 1, scryptR, scryptP},
+		{scryptLogN, scryptMaxR + 1, scryptP},
+		{scryptLogN, scryptR, scryptMaxP + 1},
+	} {
+		body := append(append([]byte{}, params...), make([]byte, saltSize+fileKeySize+16)...)
+		_, err := passphrase.Unwrap([]Stanza{{Type: stanzaScrypt, Body: body}})
+		if err == nil || errors.Is(err, ErrNoIdentity) || !strings.Contains(err.Error(), "too high") {
+			t.Errorf("params %v: %v, want too high error", params, err)
+		}
+	}
+}
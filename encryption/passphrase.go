@@ -0,0 +1,130 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// stanzaScrypt - тип записи заголовка с ключом файла, зашифрованным ключом из парольной фразы
+const stanzaScrypt = 1
+
+// Параметры scrypt для новых архивов (N = 2^15, r = 8, p = 1: около 32 МБ памяти)
+// и ограничение стоимости при чтении, чтобы поврежденный заголовок не исчерпал память:
+// память scrypt - 128 * r * N байт, то есть не больше 1 ГиБ при N = 2^20 и r = 8
+const (
+	scryptLogN    = 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptMaxLogN = 20
+	scryptMaxR    = 8
+	scryptMaxP    = 4
+	saltSize      = 16
+)
+
+// Passphrase получает парольную фразу из переменной окружения Env или из файла File.
+// Фраза читается при каждом шифровании и расшифровке, поэтому ее не нужно задавать
+// для команд, которые не работают с зашифрованными архивами
+type Passphrase struct {
+	Env  string
+	File string
+}
+
+// Read возвращает парольную фразу. Завершающий перевод строки файла отбрасывается
+func (p Passphrase) Read() ([]byte, error) {
+	var passphrase []byte
+
+	switch {
+	case p.Env != "":
+		value, ok := os.LookupEnv(p.Env)
+		if !ok {
+			return nil, fmt.Errorf("passphrase environment variable %s is not set", p.Env)
+		}
+		passphrase = []byte(value)
+	case p.File != "":
+		data, err := os.ReadFile(p.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		passphrase = []byte(strings.TrimRight(string(data), "\r\n"))
+	default:
+		return nil, fmt.Errorf("no passphrase source configured")
+	}
+
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	return passphrase, nil
+}
+
+// Wrap шифрует ключ файла ключом, полученным из парольной фразы через scrypt со случайной солью
+func (p Passphrase) Wrap(fileKey []byte) (Stanza, error) {
+	passphrase, err := p.Read()
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return Stanza{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	params := []byte{scryptLogN, scryptR, scryptP}
+	aead, err := scryptAEAD(passphrase, salt, params)
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	// Ключ уникален благодаря соли, поэтому нулевой nonce безопасен
+	body := append(append(params, salt...), aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, params)...)
+	return Stanza{Type: stanzaScrypt, Body: body}, nil
+}
+
+// Unwrap расшифровывает ключ файла из записи scrypt. Неверная парольная фраза - ошибка ErrNoIdentity
+func (p Passphrase) Unwrap(stanzas []Stanza) ([]byte, error) {
+	for _, stanza := range stanzas {
+		if stanza.Type != stanzaScrypt {
+			continue
+		}
+
+		if len(stanza.Body) != 3+saltSize+fileKeySize+16 {
+			return nil, fmt.Errorf("invalid passphrase entry in encryption header")
+		}
+		params := stanza.Body[:3]
+		if params[0] > scryptMaxLogN || params[1] > scryptMaxR || params[2] > scryptMaxP {
+			return nil, fmt.Errorf("scrypt parameters in encryption header are too high")
+		}
+
+		passphrase, err := p.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := scryptAEAD(passphrase, stanza.Body[3:3+saltSize], params)
+		if err != nil {
+			return nil, err
+		}
+
+		// Файл может быть зашифрован и для других ключей, которые NewReader попробует следующими
+		fileKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), stanza.Body[3+saltSize:], params)
+		if err != nil {
+			return nil, fmt.Errorf("wrong passphrase: %w", ErrNoIdentity)
+		}
+		return fileKey, nil
+	}
+
+	return nil, ErrNoIdentity
+}
+
+// scryptAEAD возвращает AES-256-GCM с ключом из парольной фразы
+func scryptAEAD(passphrase, salt, params []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<params[0], int(params[1]), int(params[2]), 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return newAEAD(key)
+}
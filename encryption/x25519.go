@@ -0,0 +1,218 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// stanzaX25519 - тип записи заголовка с ключом файла, зашифрованным для открытого ключа X25519
+const stanzaX25519 = 2
+
+// Префиксы текстового представления ключей
+const (
+	PublicKeyPrefix  = "goback-pub-"
+	PrivateKeyPrefix = "GOBACK-SECRET-KEY-"
+)
+
+// x25519Info - контекст HKDF для ключа, которым шифруется ключ файла
+const x25519Info = "goback X25519 file key"
+
+// X25519Recipient - получатель, которому архив доступен по открытому ключу X25519.
+// Для шифрования нужен только открытый ключ, закрытый может храниться вне сервера
+type X25519Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// ParseRecipient разбирает открытый ключ вида goback-pub-...
+func ParseRecipient(value string) (*X25519Recipient, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, PublicKeyPrefix) {
+		return nil, fmt.Errorf("invalid public key %q: expected %s prefix", value, PublicKeyPrefix)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, PublicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", value, err)
+	}
+
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", value, err)
+	}
+	return &X25519Recipient{key: key}, nil
+}
+
+// String возвращает открытый ключ в текстовом виде
+func (r *X25519Recipient) String() string {
+	return PublicKeyPrefix + base64.RawURLEncoding.EncodeToString(r.key.Bytes())
+}
+
+// Wrap шифрует ключ файла ключом, согласованным между одноразовым ключом и ключом получателя
+func (r *X25519Recipient) Wrap(fileKey []byte) (Stanza, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return Stanza{}, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(r.key)
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	ephemeralPublic := ephemeral.PublicKey().Bytes()
+	aead, err := x25519AEAD(shared, ephemeralPublic, r.key.Bytes())
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	body := append(ephemeralPublic, aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil)...)
+	return Stanza{Type: stanzaX25519, Body: body}, nil
+}
+
+// X25519Identity - закрытый ключ X25519 для расшифровки архивов
+type X25519Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateIdentity создает новый закрытый ключ
+func GenerateIdentity() (*X25519Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &X25519Identity{key: key}, nil
+}
+
+// ParseIdentity разбирает закрытый ключ вида GOBACK-SECRET-KEY-...
+func ParseIdentity(value string) (*X25519Identity, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, PrivateKeyPrefix) {
+		return nil, fmt.Errorf("invalid private key: expected %s prefix", PrivateKeyPrefix)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, PrivateKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return &X25519Identity{key: key}, nil
+}
+
+// String возвращает закрытый ключ в текстовом виде
+func (i *X25519Identity) String() string {
+	return PrivateKeyPrefix + base64.RawURLEncoding.EncodeToString(i.key.Bytes())
+}
+
+// Recipient возвращает получателя с открытым ключом, соответствующим закрытому
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{key: i.key.PublicKey()}
+}
+
+// Unwrap ищет запись, зашифрованную для этого ключа, и расшифровывает из нее ключ файла
+func (i *X25519Identity) Unwrap(stanzas []Stanza) ([]byte, error) {
+	for _, stanza := range stanzas {
+		if stanza.Type != stanzaX25519 {
+			continue
+		}
+		if len(stanza.Body) != 32+fileKeySize+16 {
+			return nil, fmt.Errorf("invalid public key entry in encryption header")
+		}
+
+		ephemeral, err := ecdh.X25519().NewPublicKey(stanza.Body[:32])
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key entry in encryption header: %w", err)
+		}
+
+		shared, err := i.key.ECDH(ephemeral)
+		if err != nil {
+			continue
+		}
+
+		aead, err := x25519AEAD(shared, stanza.Body[:32], i.key.PublicKey().Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		// Запись для другого получателя не расшифровывается этим ключом
+		if fileKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), stanza.Body[32:], nil); err == nil {
+			return fileKey, nil
+		}
+	}
+
+	return nil, ErrNoIdentity
+}
+
+// IdentityFile - файл с закрытыми ключами (по одному в строке, строки с # пропускаются).
+// Файл читается при расшифровке, поэтому он нужен только там, где архивы восстанавливаются
+type IdentityFile string
+
+// Identities читает ключи из файла
+func (f IdentityFile) Identities() ([]*X25519Identity, error) {
+	data, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+
+	var identities []*X25519Identity
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("identity file %s: %w", string(f), err)
+		}
+		identities = append(identities, identity)
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no private keys in identity file %s", string(f))
+	}
+	return identities, nil
+}
+
+func (f IdentityFile) Unwrap(stanzas []Stanza) ([]byte, error) {
+	identities, err := f.Identities()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, identity := range identities {
+		fileKey, err := identity.Unwrap(stanzas)
+		if !errors.Is(err, ErrNoIdentity) {
+			return fileKey, err
+		}
+	}
+	return nil, ErrNoIdentity
+}
+
+// x25519AEAD возвращает AES-256-GCM с ключом из общего секрета X25519.
+// Открытые ключи обеих сторон входят в соль, привязывая ключ к конкретной паре
+func x25519AEAD(shared, ephemeralPublic, recipientPublic []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeralPublic...), recipientPublic...)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Info)), key); err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
@@ -0,0 +1,152 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func generateIdentity(t *testing.T) *X25519Identity {
+	t.Helper()
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func TestX25519KeyStrings(t *testing.T) {
+	identity := generateIdentity(t)
+
+	parsed, err := ParseIdentity(identity.String())
+	if err != nil {
+		t.Fatalf("ParseIdentity: %v", err)
+	}
+	if parsed.String() != identity.String() {
+		t.Error("private key changed after round trip")
+	}
+
+	recipient, err := ParseRecipient(identity.Recipient().String())
+	if err != nil {
+		t.Fatalf("ParseRecipient: %v", err)
+	}
+	if recipient.String() != identity.Recipient().String() {
+		t.Error("public key changed after round trip")
+	}
+	if !strings.HasPrefix(recipient.String(), "goback-pub-") || !strings.HasPrefix(identity.String(), "GOBACK-SECRET-KEY-") {
+		t.Errorf("unexpected key prefixes: %s, %s", recipient, identity)
+	}
+
+	for _, value := range []string{"", "goback-pub-", "goback-pub-AAAA", identity.String(), "age1qqqq"} {
+		if _, err := ParseRecipient(value); err == nil {
+			t.Errorf("ParseRecipient(%q) accepted invalid key", value)
+		}
+	}
+	for _, value := range []string{"", "GOBACK-SECRET-KEY-AAAA", identity.Recipient().String()} {
+		if _, err := ParseIdentity(value); err == nil {
+			t.Errorf("ParseIdentity(%q) accepted invalid key", value)
+		}
+	}
+}
+
+func TestX25519Recipients(t *testing.T) {
+	ops := generateIdentity(t)
+	escrow := generateIdentity(t)
+	other := generateIdentity(t)
+
+	data := randomData(chunkSize + 10)
+	encrypted := encrypt(t, data, ops.Recipient(), escrow.Recipient())
+
+	// Любой из получателей расшифровывает архив
+	for name, identity := range map[string]*X25519Identity{"ops": ops, "escrow": escrow} {
+		got, err := decrypt(encrypted, identity)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: data mismatch after round trip", name)
+		}
+	}
+
+	if _, err := decrypt(encrypted, other); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("other key: %v, want ErrNoIdentity", err)
+	}
+
+	// Ключи перебираются по порядку, неподходящие пропускаются
+	if _, err := decrypt(encrypted, other, escrow); err != nil {
+		t.Errorf("second identity not tried: %v", err)
+	}
+}
+
+func TestX25519WithPassphrase(t *testing.T) {
+	t.Setenv("GOBACK_TEST_PASS", "passphrase")
+	identity := generateIdentity(t)
+
+	data := randomData(100)
+	encrypted := encrypt(t, data, Passphrase{Env: "GOBACK_TEST_PASS"}, identity.Recipient())
+
+	for name, key := range map[string]Identity{"passphrase": Passphrase{Env: "GOBACK_TEST_PASS"}, "x25519": identity} {
+		got, err := decrypt(encrypted, key)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: data mismatch", name)
+		}
+	}
+}
+
+func TestX25519StanzaTampered(t *testing.T) {
+	identity := generateIdentity(t)
+	encrypted := encrypt(t, randomData(10), identity.Recipient())
+
+	// Последний байт записи получателя - часть тега, шифрующего ключ файла
+	stanzaEnd := len(magic) + 2 + 3 + 80
+	encrypted[stanzaEnd-1] ^= 1
+
+	if _, err := decrypt(encrypted, identity); err == nil {
+		t.Error("modified recipient entry accepted")
+	}
+}
+
+func TestIdentityFile(t *testing.T) {
+	ops := generateIdentity(t)
+	escrow := generateIdentity(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "keys")
+	content := "# ops key\n" + ops.String() + "\n\n  # escrow\n" + escrow.String() + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	identities, err := IdentityFile(path).Identities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 2 || identities[1].String() != escrow.String() {
+		t.Fatalf("Identities returned %d keys", len(identities))
+	}
+
+	encrypted := encrypt(t, randomData(10), escrow.Recipient())
+	if _, err := decrypt(encrypted, IdentityFile(path)); err != nil {
+		t.Errorf("identity file: %v", err)
+	}
+
+	for name, content := range map[string]string{"empty": "# nothing\n", "invalid": "not a key\n"} {
+		bad := filepath.Join(dir, name)
+		if err := os.WriteFile(bad, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := IdentityFile(bad).Identities(); err == nil {
+			t.Errorf("%s identity file accepted", name)
+		}
+	}
+
+	if _, err := decrypt(encrypted, IdentityFile(filepath.Join(dir, "missing"))); err == nil || errors.Is(err, ErrNoIdentity) {
+		t.Errorf("missing identity file: %v, want read error", err)
+	}
+}
@@ -1,9 +1,10 @@
 package hooks
 
 import (
-	"fmt"
 	"os/exec"
 	"strings"
+
+	"goback/utils"
 )
 
 func RunHooks(hooks []string) error {
@@ -23,12 +24,12 @@ func RunHooks(hooks []string) error {
 		output, err := cmd.CombinedOutput()
 		if err != nil {
 			// Логируем ошибку, но не прерываем процесс
-			fmt.Printf("Hook failed: %s\nOutput: %s\nError: %v\n", hook, string(output), err)
+			utils.Printf("Hook failed: %s\nOutput: %s\nError: %v\n", hook, string(output), err)
 			continue
 		}
 
 		if len(output) > 0 {
-			fmt.Printf("Hook output: %s\n", string(output))
+			utils.Printf("Hook output: %s\n", string(output))
 		}
 	}
 
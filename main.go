@@ -2,9 +2,9 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"os"
 	"strings"
+	_ "time/tzdata" // встроенная база часовых поясов для параметра timezone
 
 	"goback/backup"
 	"goback/config"
@@ -13,6 +13,13 @@ import (
 )
 
 func main() {
+	// Подкоманды указываются первым аргументом: ./goback restore -b name ...
+	if len(os.Args) > 1 {
+		if command, exists := subcommands[os.Args[1]]; exists {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	// Парсим флаги командной строки
 	var configPath string
 	var backupNames flagArray
@@ -82,7 +89,7 @@ func main() {
 	if !skipGlobalPreHooks && len(cfg.Global.PreHooks) > 0 {
 		utils.PrintHeader("Running global pre-hooks...")
 		if err := hooks.RunHooks(cfg.Global.PreHooks); err != nil {
-			fmt.Printf("Warning: global pre-hooks completed with errors\n")
+			utils.Printf("Warning: global pre-hooks completed with errors\n")
 		}
 	}
 
@@ -107,7 +114,7 @@ func main() {
 	if !skipGlobalPostHooks && len(cfg.Global.PostHooks) > 0 {
 		utils.PrintHeader("\nRunning global post-hooks...")
 		if err := hooks.RunHooks(cfg.Global.PostHooks); err != nil {
-			fmt.Printf("Warning: global post-hooks completed with errors\n")
+			utils.Printf("Warning: global post-hooks completed with errors\n")
 		}
 	}
 
@@ -115,15 +122,21 @@ func main() {
 	if successCount > 0 {
 		utils.PrintSuccess("Successful: %d", successCount)
 	} else {
-		fmt.Printf("Successful: %d\n", successCount)
+		utils.Printf("Successful: %d\n", successCount)
 	}
 	if errorCount > 0 {
 		utils.PrintError("Failed: %d", errorCount)
 	} else {
-		fmt.Printf("Failed: %d\n", errorCount)
+		utils.Printf("Failed: %d\n", errorCount)
 	}
 
-	if errorCount > 0 {
+	if stored := executor.StoreStats(); stored.Files > 0 {
+		utils.Printf("Stored without recompression: %d file(s), %s\n", stored.Files, utils.FormatSize(stored.Bytes))
+	}
+
+	drillsPassed := printDrillReport(executor.DrillResults())
+
+	if errorCount > 0 || !drillsPassed {
 		os.Exit(1)
 	}
 }
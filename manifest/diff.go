@@ -0,0 +1,88 @@
+package manifest
+
+import "sort"
+
+// Виды изменений между двумя манифестами
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+	ChangeMode     = "mode"
+	// ChangeSkipped - файл есть в старом бэкапе, но не был прочитан при новом
+	ChangeSkipped = "skipped"
+)
+
+// Change описывает изменение файла между двумя бэкапами
+type Change struct {
+	Path string
+	Kind string
+	// Type - тип элемента в новом бэкапе, для удаленных и пропущенных - в старом
+	Type    string
+	OldSize int64
+	NewSize int64
+	OldMode string
+	NewMode string
+}
+
+// Diff сравнивает содержимое двух бэкапов и возвращает изменения, отсортированные по пути.
+// Файл считается измененным, если изменились тип, размер, хеш или цель симлинка;
+// изменение только прав отмечается как ChangeMode. Отсутствующий в новом бэкапе файл, который
+// не удалось прочитать, отмечается как ChangeSkipped, а не как удаленный
+func Diff(old, new *Manifest) []Change {
+	oldFiles := make(map[string]File, len(old.Files))
+	for _, file := range old.Files {
+		oldFiles[file.Path] = file
+	}
+
+	newFiles := make(map[string]File, len(new.Files))
+	for _, file := range new.Files {
+		newFiles[file.Path] = file
+	}
+
+	var changes []Change
+
+	for path, newFile := range newFiles {
+		oldFile, exists := oldFiles[path]
+		if !exists {
+			changes = append(changes, Change{Path: path, Kind: ChangeAdded, Type: newFile.Type, NewSize: newFile.Size, NewMode: newFile.Mode})
+			continue
+		}
+
+		change := Change{
+			Path:    path,
+			Type:    newFile.Type,
+			OldSize: oldFile.Size,
+			NewSize: newFile.Size,
+			OldMode: oldFile.Mode,
+			NewMode: newFile.Mode,
+		}
+
+		switch {
+		case oldFile.Type != newFile.Type || oldFile.Size != newFile.Size ||
+			oldFile.SHA256 != newFile.SHA256 || oldFile.Link != newFile.Link:
+			change.Kind = ChangeModified
+		case oldFile.Mode != newFile.Mode:
+			change.Kind = ChangeMode
+		default:
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	for path, oldFile := range oldFiles {
+		if _, exists := newFiles[path]; !exists {
+			kind := ChangeRemoved
+			if new.IsSkipped(path) {
+				kind = ChangeSkipped
+			}
+			changes = append(changes, Change{Path: path, Kind: kind, Type: oldFile.Type, OldSize: oldFile.Size, OldMode: oldFile.Mode})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
@@ -0,0 +1,80 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := New("app", "/srv/app")
+	old.AddFile(File{Path: "same.txt", Type: TypeFile, Size: 3, Mode: "0644", SHA256: "aaa"})
+	old.AddFile(File{Path: "removed.txt", Type: TypeFile, Size: 5, Mode: "0644", SHA256: "bbb"})
+	old.AddFile(File{Path: "content.txt", Type: TypeFile, Size: 4, Mode: "0644", SHA256: "ccc"})
+	old.AddFile(File{Path: "size.txt", Type: TypeFile, Size: 4, Mode: "0644", SHA256: "ddd"})
+	old.AddFile(File{Path: "mode.sh", Type: TypeFile, Size: 6, Mode: "0644", SHA256: "eee"})
+	old.AddFile(File{Path: "both.sh", Type: TypeFile, Size: 6, Mode: "0644", SHA256: "fff"})
+	old.AddFile(File{Path: "link", Type: TypeSymlink, Mode: "0777", Link: "same.txt"})
+	old.AddFile(File{Path: "type", Type: TypeSymlink, Mode: "0777", Link: "same.txt"})
+
+	new := New("app", "/srv/app")
+	new.AddFile(File{Path: "type", Type: TypeFile, Mode: "0777"})
+	new.AddFile(File{Path: "link", Type: TypeSymlink, Mode: "0777", Link: "content.txt"})
+	new.AddFile(File{Path: "both.sh", Type: TypeFile, Size: 7, Mode: "0755", SHA256: "000"})
+	new.AddFile(File{Path: "mode.sh", Type: TypeFile, Size: 6, Mode: "0755", SHA256: "eee"})
+	new.AddFile(File{Path: "size.txt", Type: TypeFile, Size: 8, Mode: "0644", SHA256: "ddd"})
+	new.AddFile(File{Path: "content.txt", Type: TypeFile, Size: 4, Mode: "0644", SHA256: "111"})
+	new.AddFile(File{Path: "added.txt", Type: TypeFile, Size: 2, Mode: "0600", SHA256: "222"})
+	new.AddFile(File{Path: "same.txt", Type: TypeFile, Size: 3, Mode: "0644", SHA256: "aaa"})
+
+	want := []Change{
+		{Path: "added.txt", Kind: ChangeAdded, Type: TypeFile, NewSize: 2, NewMode: "0600"},
+		{Path: "both.sh", Kind: ChangeModified, Type: TypeFile, OldSize: 6, NewSize: 7, OldMode: "0644", NewMode: "0755"},
+		{Path: "content.txt", Kind: ChangeModified, Type: TypeFile, OldSize: 4, NewSize: 4, OldMode: "0644", NewMode: "0644"},
+		{Path: "link", Kind: ChangeModified, Type: TypeSymlink, OldMode: "0777", NewMode: "0777"},
+		{Path: "mode.sh", Kind: ChangeMode, Type: TypeFile, OldSize: 6, NewSize: 6, OldMode: "0644", NewMode: "0755"},
+		{Path: "removed.txt", Kind: ChangeRemoved, Type: TypeFile, OldSize: 5, OldMode: "0644"},
+		{Path: "size.txt", Kind: ChangeModified, Type: TypeFile, OldSize: 4, NewSize: 8, OldMode: "0644", NewMode: "0644"},
+		{Path: "type", Kind: ChangeModified, Type: TypeFile, OldMode: "0777", NewMode: "0777"},
+	}
+
+	got := Diff(old, new)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Errorf("Diff of identical manifests = %+v", changes)
+	}
+}
+
+func TestDiffDirectoriesAndSkipped(t *testing.T) {
+	old := New("app", "/srv/app")
+	old.AddFile(File{Path: "cache", Type: TypeDirectory, Mode: "0755"})
+	old.AddFile(File{Path: "conf", Type: TypeDirectory, Mode: "0755"})
+	old.AddFile(File{Path: "conf/secret.key", Type: TypeFile, Size: 32, Mode: "0600", SHA256: "aaa"})
+	old.AddFile(File{Path: "private", Type: TypeDirectory, Mode: "0700"})
+	old.AddFile(File{Path: "private/data", Type: TypeFile, Size: 4, Mode: "0600", SHA256: "bbb"})
+	old.AddFile(File{Path: "tmp", Type: TypeDirectory, Mode: "0755"})
+
+	new := New("app", "/srv/app")
+	new.AddFile(File{Path: "cache", Type: TypeDirectory, Mode: "0700"})
+	new.AddFile(File{Path: "conf", Type: TypeDirectory, Mode: "0755"})
+	new.AddFile(File{Path: "logs", Type: TypeDirectory, Mode: "0755"})
+	new.AddFile(File{Path: "private", Type: TypeDirectory, Mode: "0700"})
+	new.AddSkipped("conf/secret.key")
+	// Содержимое директории private не удалось прочитать
+	new.AddSkipped("private/")
+
+	want := []Change{
+		{Path: "cache", Kind: ChangeMode, Type: TypeDirectory, OldMode: "0755", NewMode: "0700"},
+		{Path: "conf/secret.key", Kind: ChangeSkipped, Type: TypeFile, OldSize: 32, OldMode: "0600"},
+		{Path: "logs", Kind: ChangeAdded, Type: TypeDirectory, NewMode: "0755"},
+		{Path: "private/data", Kind: ChangeSkipped, Type: TypeFile, OldSize: 4, OldMode: "0600"},
+		{Path: "tmp", Kind: ChangeRemoved, Type: TypeDirectory, OldMode: "0755"},
+	}
+
+	got := Diff(old, new)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"goback/storage"
+)
+
+// Типы элементов манифеста
+const (
+	TypeFile      = "file"
+	TypeSymlink   = "symlink"
+	TypeDirectory = "directory"
+)
+
+// File описывает файл, симлинк или директорию, попавшие в архив
+type File struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256,omitempty"`
+	Link    string    `json:"link,omitempty"`
+}
+
+// Manifest - список содержимого бэкапа директории: включенные файлы и директории,
+// пропущенные по exclude_patterns пути, отброшенные специальные файлы и пути,
+// которые не удалось прочитать
+type Manifest struct {
+	Backup   string    `json:"backup"`
+	Source   string    `json:"source"`
+	Created  time.Time `json:"created"`
+	Files    []File    `json:"files"`
+	Excluded []string  `json:"excluded"`
+	Special  []string  `json:"special"`
+	Skipped  []string  `json:"skipped"`
+}
+
+// New создает пустой манифест бэкапа
+func New(backup, source string) *Manifest {
+	return &Manifest{
+		Backup:   backup,
+		Source:   source,
+		Files:    []File{},
+		Excluded: []string{},
+		Special:  []string{},
+		Skipped:  []string{},
+	}
+}
+
+// AddFile добавляет файл в манифест
+func (m *Manifest) AddFile(file File) {
+	m.Files = append(m.Files, file)
+}
+
+// AddExcluded отмечает путь, пропущенный по exclude_patterns
+func (m *Manifest) AddExcluded(path string) {
+	m.Excluded = append(m.Excluded, path)
+}
+
+// AddSpecial отмечает отброшенный специальный файл (socket, pipe, device)
+func (m *Manifest) AddSpecial(path string) {
+	m.Special = append(m.Special, path)
+}
+
+// AddSkipped отмечает путь, который не удалось прочитать; директория отмечается с "/" на конце
+func (m *Manifest) AddSkipped(path string) {
+	m.Skipped = append(m.Skipped, path)
+}
+
+// IsSkipped сообщает, что путь не удалось прочитать при бэкапе: он сам или одна из его
+// родительских директорий отмечены как пропущенные
+func (m *Manifest) IsSkipped(path string) bool {
+	for _, skipped := range m.Skipped {
+		if skipped == path || (strings.HasSuffix(skipped, "/") && strings.HasPrefix(path, skipped)) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatMode возвращает права файла в восьмеричном виде (0644)
+func FormatMode(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
+
+// Write сохраняет манифест в JSON в объект name хранилища
+func (m *Manifest) Write(b storage.Backend, name string) error {
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+	m.Created = time.Now()
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	if err := storage.WriteFile(b, name, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// Load читает манифест из JSON из объекта name хранилища
+func Load(b storage.Backend, name string) (*Manifest, error) {
+	data, err := storage.ReadFile(b, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	return &m, nil
+}
@@ -1,13 +1,15 @@
 package retention
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"sort"
 	"strings"
 	"time"
 
+	"goback/storage"
 	"goback/utils"
 )
 
@@ -18,20 +20,36 @@ type RetentionPolicy struct {
 	Yearly  int
 }
 
+// Уровни хранения бэкапов
+const (
+	TierDaily   = "daily"
+	TierWeekly  = "weekly"
+	TierMonthly = "monthly"
+	TierYearly  = "yearly"
+)
+
 type BackupFile struct {
+	// Path - имя архива в хранилище; для архива, разбитого на тома, - имя без номера тома
 	Path string
 	Time time.Time
+	// Size - размер архива, для томов - суммарный
+	Size int64
+	// Volumes - тома архива по порядку; пусто, если архив хранится одним файлом
+	Volumes []string
 }
 
-// ApplyRetention применяет политику хранения к бэкапам
-func ApplyRetention(backupDir, subdirectory, backupName string, policy RetentionPolicy) error {
-	backupPath := filepath.Join(backupDir, subdirectory)
-	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
-		return nil // Директория не существует, нечего чистить
+// Files возвращает объекты архива в хранилище: тома или сам архив
+func (f BackupFile) Files() []string {
+	if len(f.Volumes) > 0 {
+		return f.Volumes
 	}
+	return []string{f.Path}
+}
 
+// ApplyRetention применяет политику хранения к бэкапам в поддиректории subdirectory хранилища b
+func ApplyRetention(b storage.Backend, subdirectory, backupName string, policy RetentionPolicy) error {
 	// Получаем все файлы бэкапов, фильтруя по имени бэкапа
-	files, err := getBackupFiles(backupPath, backupName)
+	files, err := getBackupFiles(b, subdirectory, backupName)
 	if err != nil {
 		return fmt.Errorf("failed to get backup files: %w", err)
 	}
@@ -54,10 +72,11 @@ func ApplyRetention(backupDir, subdirectory, backupName string, policy Retention
 		}
 
 		if !shouldKeep {
-			if err := os.Remove(file.Path); err != nil {
-				fmt.Printf("Warning: failed to remove old backup %s: %v\n", file.Path, err)
+			if err := removeArchive(b, file); err != nil {
+				utils.Printf("Warning: failed to remove old backup %s: %v\n", file.Path, err)
 			} else {
-				fmt.Printf("Removed old backup: %s\n", filepath.Base(file.Path))
+				utils.Printf("Removed old backup: %s\n", path.Base(file.Path))
+				removeSidecars(b, file.Path)
 			}
 		}
 	}
@@ -65,8 +84,47 @@ func ApplyRetention(backupDir, subdirectory, backupName string, policy Retention
 	return nil
 }
 
-func getBackupFiles(dir, backupName string) ([]BackupFile, error) {
-	entries, err := os.ReadDir(dir)
+// removeArchive удаляет архив или все его тома
+func removeArchive(b storage.Backend, file BackupFile) error {
+	for _, name := range file.Files() {
+		if err := b.Delete(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSidecars удаляет вспомогательные файлы архива
+func removeSidecars(b storage.Backend, name string) {
+	for _, ext := range utils.SidecarExtensions {
+		if err := b.Delete(name + ext); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			utils.Printf("Warning: failed to remove %s: %v\n", name+ext, err)
+		}
+	}
+}
+
+// ListBackups возвращает бэкапы с указанным именем, отсортированные от старых к новым.
+// Время из имен архивов интерпретируется в часовом поясе loc
+func ListBackups(b storage.Backend, subdirectory, backupName string, loc *time.Location) ([]BackupFile, error) {
+	files, err := getBackupFiles(b, subdirectory, backupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup files: %w", err)
+	}
+
+	for i := range files {
+		t := files[i].Time
+		files[i].Time = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Time.Before(files[j].Time)
+	})
+
+	return files, nil
+}
+
+func getBackupFiles(backend storage.Backend, dir, backupName string) ([]BackupFile, error) {
+	entries, err := backend.List(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -75,6 +133,9 @@ func getBackupFiles(dir, backupName string) ([]BackupFile, error) {
 	namePrefix := backupName + "-"
 
 	var files []BackupFile
+	// Тома одного архива собираются в один BackupFile
+	volumeSets := make(map[string]int)
+
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -82,10 +143,23 @@ func getBackupFiles(dir, backupName string) ([]BackupFile, error) {
 
 		// Фильтруем файлы по префиксу имени бэкапа
 		entryName := entry.Name()
+
+		// Вспомогательные файлы (контрольные суммы и т.п.) обрабатываются вместе с архивом
+		if utils.IsSidecar(entryName) {
+			continue
+		}
+
+		archiveName := entryName
+		volume := false
+		if base, _, ok := utils.SplitVolume(entryName); ok {
+			archiveName = base
+			volume = true
+		}
+
 		// Убираем расширение для проверки префикса
-		baseName := entryName
-		if idx := strings.LastIndex(entryName, "."); idx != -1 {
-			baseName = entryName[:idx]
+		baseName := archiveName
+		if idx := strings.LastIndex(archiveName, "."); idx != -1 {
+			baseName = archiveName[:idx]
 		}
 
 		// Проверяем, что имя файла начинается с {backupName}-
@@ -93,17 +167,44 @@ func getBackupFiles(dir, backupName string) ([]BackupFile, error) {
 			continue
 		}
 
-		path := filepath.Join(dir, entryName)
-		t, err := utils.ParseDateFromFilename(entryName)
+		name := storage.Join(dir, archiveName)
+		t, err := utils.ParseDateFromFilename(archiveName)
 		if err != nil {
 			// Пропускаем файлы, из которых нельзя извлечь дату
 			continue
 		}
 
-		files = append(files, BackupFile{
-			Path: path,
+		size := entry.Size()
+
+		if volume {
+			if i, exists := volumeSets[name]; exists {
+				files[i].Volumes = append(files[i].Volumes, storage.Join(dir, entryName))
+				files[i].Size += size
+				continue
+			}
+			volumeSets[name] = len(files)
+		}
+
+		file := BackupFile{
+			Path: name,
 			Time: t,
-		})
+			Size: size,
+		}
+		if volume {
+			file.Volumes = []string{storage.Join(dir, entryName)}
+		}
+		files = append(files, file)
+	}
+
+	// Упорядочиваем тома по номеру: после .999 алфавитный порядок имен с ним не совпадает
+	for _, file := range files {
+		if len(file.Volumes) > 1 {
+			sort.Slice(file.Volumes, func(i, j int) bool {
+				_, a, _ := utils.SplitVolume(file.Volumes[i])
+				_, b, _ := utils.SplitVolume(file.Volumes[j])
+				return a < b
+			})
+		}
 	}
 
 	return files, nil
@@ -114,13 +215,31 @@ func determineFilesToKeep(files []BackupFile, policy RetentionPolicy) []BackupFi
 		return files
 	}
 
+	tiers := ClassifyBackups(files, policy)
+
+	result := make([]BackupFile, 0, len(tiers))
+	for _, file := range files {
+		if len(tiers[file.Path]) > 0 {
+			result = append(result, file)
+		}
+	}
+
+	return result
+}
+
+// ClassifyBackups возвращает для каждого пути бэкапа уровни хранения (daily, weekly, monthly, yearly),
+// которые его удерживают. Бэкапы без уровней будут удалены при следующем применении политики
+func ClassifyBackups(files []BackupFile, policy RetentionPolicy) map[string][]string {
+	tiers := make(map[string][]string)
+	if len(files) == 0 {
+		return tiers
+	}
+
 	// Сортируем по времени (от старых к новым)
 	sort.Slice(files, func(i, j int) bool {
 		return files[i].Time.Before(files[j].Time)
 	})
 
-	var toKeep []BackupFile
-
 	// Группируем по периодам
 	dailyAnchors := getAnchors(files, func(t time.Time) time.Time {
 		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
@@ -141,23 +260,20 @@ func determineFilesToKeep(files []BackupFile, policy RetentionPolicy) []BackupFi
 	})
 
 	// Берем N последних якорных точек каждого типа
-	toKeep = append(toKeep, getLastN(dailyAnchors, policy.Daily)...)
-	toKeep = append(toKeep, getLastN(weeklyAnchors, policy.Weekly)...)
-	toKeep = append(toKeep, getLastN(monthlyAnchors, policy.Monthly)...)
-	toKeep = append(toKeep, getLastN(yearlyAnchors, policy.Yearly)...)
-
-	// Убираем дубликаты
-	unique := make(map[string]BackupFile)
-	for _, file := range toKeep {
-		unique[file.Path] = file
+	for _, file := range getLastN(dailyAnchors, policy.Daily) {
+		tiers[file.Path] = append(tiers[file.Path], TierDaily)
 	}
-
-	result := make([]BackupFile, 0, len(unique))
-	for _, file := range unique {
-		result = append(result, file)
+	for _, file := range getLastN(weeklyAnchors, policy.Weekly) {
+		tiers[file.Path] = append(tiers[file.Path], TierWeekly)
+	}
+	for _, file := range getLastN(monthlyAnchors, policy.Monthly) {
+		tiers[file.Path] = append(tiers[file.Path], TierMonthly)
+	}
+	for _, file := range getLastN(yearlyAnchors, policy.Yearly) {
+		tiers[file.Path] = append(tiers[file.Path], TierYearly)
 	}
 
-	return result
+	return tiers
 }
 
 // getAnchors возвращает последний бэкап для каждого периода
@@ -0,0 +1,89 @@
+package retention
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"goback/storage"
+	"goback/utils"
+)
+
+// retentionTimes - ежедневные бэкапы с 25.09 по 08.10.2026, второй бэкап 08.10 и один бэкап прошлого года
+func retentionTimes() []time.Time {
+	times := []time.Time{time.Date(2025, 12, 31, 3, 0, 0, 0, time.UTC)}
+	for t := time.Date(2026, 9, 25, 3, 0, 0, 0, time.UTC); !t.After(time.Date(2026, 10, 8, 3, 0, 0, 0, time.UTC)); t = t.AddDate(0, 0, 1) {
+		times = append(times, t)
+	}
+	return append(times, time.Date(2026, 10, 8, 15, 0, 0, 0, time.UTC))
+}
+
+var testPolicy = RetentionPolicy{Daily: 3, Weekly: 2, Monthly: 2, Yearly: 2}
+
+// keptTiers - ожидаемые уровни хранения для testPolicy; недели начинаются с понедельника (28.09 и 05.10)
+var keptTiers = map[string][]string{
+	"app-20261008150000.tar": {TierDaily, TierWeekly, TierMonthly, TierYearly},
+	"app-20261007030000.tar": {TierDaily},
+	"app-20261006030000.tar": {TierDaily},
+	"app-20261004030000.tar": {TierWeekly},
+	"app-20260930030000.tar": {TierMonthly},
+	"app-20251231030000.tar": {TierYearly},
+}
+
+func TestClassifyBackups(t *testing.T) {
+	files := backupsAt(retentionTimes()...)
+	// Порядок входных данных не важен
+	files[0], files[len(files)-1] = files[len(files)-1], files[0]
+
+	tiers := ClassifyBackups(files, testPolicy)
+	if !reflect.DeepEqual(tiers, keptTiers) {
+		t.Errorf("ClassifyBackups = %v, want %v", tiers, keptTiers)
+	}
+
+	if tiers := ClassifyBackups(files, RetentionPolicy{}); len(tiers) != 0 {
+		t.Errorf("empty policy keeps %v", tiers)
+	}
+	if tiers := ClassifyBackups(nil, testPolicy); len(tiers) != 0 {
+		t.Errorf("no files classified as %v", tiers)
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+	for _, file := range backupsAt(retentionTimes()...) {
+		for _, name := range []string{file.Path, file.Path + utils.ChecksumExtension, file.Path + utils.SignatureExtension} {
+			if err := storage.WriteFile(b, storage.Join("app", name), []byte(name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Файлы другого бэкапа не затрагиваются
+	if err := storage.WriteFile(b, "app/other-20200101000000.tar", []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyRetention(b, "app", "app", testPolicy); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := b.List("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+
+	want := []string{"other-20200101000000.tar"}
+	for name := range keptTiers {
+		want = append(want, name, name+utils.ChecksumExtension, name+utils.SignatureExtension)
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after retention:\n got %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+package retention
+
+import (
+	"fmt"
+	"time"
+)
+
+// Selector выбирает бэкап по времени. Пустой Selector выбирает последний бэкап
+type Selector struct {
+	// At - выбрать последний бэкап не позже указанного времени
+	At time.Time
+	// Before - выбрать последний бэкап строго раньше указанного времени
+	Before time.Time
+}
+
+// IsLatest проверяет, что селектор выбирает последний бэкап
+func (s Selector) IsLatest() bool {
+	return s.At.IsZero() && s.Before.IsZero()
+}
+
+// String возвращает описание селектора для сообщений
+func (s Selector) String() string {
+	switch {
+	case !s.At.IsZero():
+		return "at " + s.At.Format(time.RFC3339)
+	case !s.Before.IsZero():
+		return "before " + s.Before.Format(time.RFC3339)
+	default:
+		return "latest"
+	}
+}
+
+// Select возвращает самый новый бэкап, подходящий под селектор.
+// Файлы должны быть отсортированы от старых к новым (см. ListBackups)
+func Select(files []BackupFile, selector Selector) (BackupFile, error) {
+	for i := len(files) - 1; i >= 0; i-- {
+		t := files[i].Time
+		if !selector.At.IsZero() && t.After(selector.At) {
+			continue
+		}
+		if !selector.Before.IsZero() && !t.Before(selector.Before) {
+			continue
+		}
+		return files[i], nil
+	}
+
+	return BackupFile{}, fmt.Errorf("no archive found %s", selector)
+}
@@ -0,0 +1,109 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"goback/storage"
+)
+
+func backupsAt(times ...time.Time) []BackupFile {
+	files := make([]BackupFile, 0, len(times))
+	for _, t := range times {
+		files = append(files, BackupFile{Path: "app-" + t.Format("20060102150405") + ".tar", Time: t})
+	}
+	return files
+}
+
+func TestSelect(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, time.UTC) }
+	files := backupsAt(day(1, 3), day(2, 3), day(3, 3))
+
+	tests := []struct {
+		name     string
+		selector Selector
+		want     time.Time
+		wantErr  bool
+	}{
+		{name: "latest", selector: Selector{}, want: day(3, 3)},
+		{name: "at exact", selector: Selector{At: day(2, 3)}, want: day(2, 3)},
+		{name: "at between", selector: Selector{At: day(2, 12)}, want: day(2, 3)},
+		{name: "at after last", selector: Selector{At: day(9, 0)}, want: day(3, 3)},
+		{name: "at before first", selector: Selector{At: day(1, 0)}, wantErr: true},
+		{name: "before exact", selector: Selector{Before: day(2, 3)}, want: day(1, 3)},
+		{name: "before between", selector: Selector{Before: day(2, 12)}, want: day(2, 3)},
+		{name: "before first", selector: Selector{Before: day(1, 3)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Select(files, tt.selector)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Select = %s, want error", tt.name, got.Path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Select error: %v", tt.name, err)
+			continue
+		}
+		if !got.Time.Equal(tt.want) {
+			t.Errorf("%s: Select = %v, want %v", tt.name, got.Time, tt.want)
+		}
+	}
+
+	if _, err := Select(nil, Selector{}); err == nil {
+		t.Error("Select on empty list returned no error")
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+	for _, name := range []string{
+		"app-20261002030000.tar.gz",
+		"app-20261002030000.tar.gz.sha256",
+		"app-20261001030000.tar.gz",
+		"app-20261003030000.tar.002",
+		"app-20261003030000.tar.001",
+		"app-old.tar.gz",
+		"application-20261004030000.tar.gz",
+		"other-20261005030000.tar.gz",
+	} {
+		if err := storage.WriteFile(b, storage.Join("app", name), []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ListBackups(b, "app", "app", moscow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app/app-20261001030000.tar.gz", "app/app-20261002030000.tar.gz", "app/app-20261003030000.tar"}
+	if len(files) != len(want) {
+		t.Fatalf("ListBackups returned %d files, want %d: %+v", len(files), len(want), files)
+	}
+	for i, file := range files {
+		if file.Path != want[i] {
+			t.Errorf("files[%d] = %s, want %s", i, file.Path, want[i])
+		}
+	}
+
+	// Время из имени архива читается в переданном часовом поясе
+	if !files[0].Time.Equal(time.Date(2026, 10, 1, 3, 0, 0, 0, moscow)) {
+		t.Errorf("files[0].Time = %v, want 03:00 Moscow time", files[0].Time)
+	}
+
+	volumes := files[2].Volumes
+	if len(volumes) != 2 || volumes[0] != "app/app-20261003030000.tar.001" || volumes[1] != "app/app-20261003030000.tar.002" {
+		t.Errorf("volumes = %v", volumes)
+	}
+	if files[2].Size != int64(len("app-20261003030000.tar.001")+len("app-20261003030000.tar.002")) {
+		t.Errorf("volume set size = %d", files[2].Size)
+	}
+}
@@ -0,0 +1,155 @@
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"goback/storage"
+	"goback/utils"
+)
+
+// Префиксы текстового представления ключей подписи
+const (
+	PublicKeyPrefix  = "goback-sig-"
+	PrivateKeyPrefix = "GOBACK-SIGNING-KEY-"
+)
+
+// ErrUnsigned возвращается для архива без файла подписи
+var ErrUnsigned = errors.New("archive is not signed")
+
+// Подписывается файл контрольных сумм <archive>.sha256: он покрывает все тома архива,
+// а проверка подписи не требует чтения самого архива. Подпись Ed25519 хранится в <archive>.sig
+// в base64 одной строкой
+
+// GenerateKey создает новый ключ подписи
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
+}
+
+// FormatPublicKey возвращает открытый ключ в текстовом виде
+func FormatPublicKey(key ed25519.PublicKey) string {
+	return PublicKeyPrefix + base64.RawURLEncoding.EncodeToString(key)
+}
+
+// FormatPrivateKey возвращает ключ подписи в текстовом виде
+func FormatPrivateKey(key ed25519.PrivateKey) string {
+	return PrivateKeyPrefix + base64.RawURLEncoding.EncodeToString(key.Seed())
+}
+
+// ParsePublicKey разбирает открытый ключ вида goback-sig-...
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, PublicKeyPrefix) {
+		return nil, fmt.Errorf("invalid public key %q: expected %s prefix", value, PublicKeyPrefix)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, PublicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", value, err)
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key %q: wrong length", value)
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// ParsePrivateKey разбирает ключ подписи вида GOBACK-SIGNING-KEY-...
+func ParsePrivateKey(value string) (ed25519.PrivateKey, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, PrivateKeyPrefix) {
+		return nil, fmt.Errorf("invalid signing key: expected %s prefix", PrivateKeyPrefix)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, PrivateKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	if len(data) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key: wrong length")
+	}
+	return ed25519.NewKeyFromSeed(data), nil
+}
+
+// LoadPrivateKey читает ключ подписи из файла. Строки с # и пустые строки пропускаются
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, err := ParsePrivateKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("signing key file %s: %w", path, err)
+		}
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("no signing key in file %s", path)
+}
+
+// Exists проверяет наличие файла подписи у архива
+func Exists(b storage.Backend, name string) bool {
+	return storage.Exists(b, name+utils.SignatureExtension)
+}
+
+// Sign подписывает файл контрольных сумм архива и записывает подпись в <archive>.sig
+func Sign(b storage.Backend, name string, key ed25519.PrivateKey) error {
+	sums, err := storage.ReadFile(b, name+utils.ChecksumExtension)
+	if err != nil {
+		return fmt.Errorf("failed to sign archive: %w", err)
+	}
+
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, sums)) + "\n"
+	if err := storage.WriteFile(b, name+utils.SignatureExtension, []byte(sig)); err != nil {
+		return fmt.Errorf("failed to write signature file: %w", err)
+	}
+
+	return nil
+}
+
+// Verify проверяет, что файл контрольных сумм архива подписан одним из ключей keys.
+// Соответствие архива контрольным суммам проверяется отдельно (checksum.VerifyStrict)
+func Verify(b storage.Backend, name string, keys []ed25519.PublicKey) error {
+	data, err := storage.ReadFile(b, name+utils.SignatureExtension)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrUnsigned
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read signature file: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature file: %s", path.Base(name+utils.SignatureExtension))
+	}
+
+	sums, err := storage.ReadFile(b, name+utils.ChecksumExtension)
+	if err != nil {
+		return fmt.Errorf("failed to read signed checksum file: %w", err)
+	}
+
+	for _, key := range keys {
+		if ed25519.Verify(key, sums, sig) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("signature mismatch: checksum file is not signed by a trusted key")
+}
@@ -0,0 +1,82 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"goback/storage"
+	"goback/utils"
+)
+
+func TestKeyRoundTrip(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParsePrivateKey(FormatPrivateKey(key))
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	if !parsed.Equal(key) {
+		t.Error("private key changed after round trip")
+	}
+
+	public, err := ParsePublicKey(FormatPublicKey(key.Public().(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %v", err)
+	}
+	if !public.Equal(key.Public()) {
+		t.Error("public key changed after round trip")
+	}
+
+	if _, err := ParsePublicKey("goback-sig-invalid"); err == nil {
+		t.Error("ParsePublicKey accepted invalid key")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	b := storage.NewLocal(t.TempDir())
+	const name = "app_2024-01-02_03-04-05.tar"
+	sums := []byte("0000000000000000000000000000000000000000000000000000000000000000  " + name + "\n")
+	if err := storage.WriteFile(b, name+utils.ChecksumExtension, sums); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}
+
+	if err := Verify(b, name, trusted); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("Verify without signature = %v, want ErrUnsigned", err)
+	}
+
+	if err := Sign(b, name, key); err != nil {
+		t.Fatal(err)
+	}
+	if !Exists(b, name) {
+		t.Fatal("signature file not written")
+	}
+	if err := Verify(b, name, trusted); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if err := Verify(b, name, []ed25519.PublicKey{other.Public().(ed25519.PublicKey)}); err == nil {
+		t.Error("Verify accepted signature from untrusted key")
+	}
+
+	tampered := append([]byte("1"), sums[1:]...)
+	if err := storage.WriteFile(b, name+utils.ChecksumExtension, tampered); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(b, name, trusted); err == nil {
+		t.Error("Verify accepted modified checksum file")
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Local - хранилище в локальной директории
+type Local struct {
+	root string
+}
+
+// NewLocal возвращает хранилище с корнем в директории root
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// Path возвращает путь объекта в файловой системе
+func (l *Local) Path(name string) string {
+	return filepath.Join(l.root, filepath.FromSlash(name))
+}
+
+// Put записывает объект во временный файл рядом с ним и переименовывает его после
+// успешной записи, поэтому прерванная запись не оставляет обрезанный объект
+func (l *Local) Put(name string, r io.Reader) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+
+	target := l.Path(name)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := createTemp(filepath.Dir(target), filepath.Base(target))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// createTemp создает временный файл для объекта base в директории dir. В отличие от os.CreateTemp
+// права файла, как у os.Create, определяются umask, а не всегда 0600
+func createTemp(dir, base string) (*os.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 10)+".tmp")
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return file, err
+	}
+	return nil, fmt.Errorf("failed to create temporary file for %s in %s", base, dir)
+}
+
+func (l *Local) List(dir string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(l.Path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []fs.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Файл удален во время чтения директории
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (l *Local) Stat(name string) (fs.FileInfo, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	return os.Stat(l.Path(name))
+}
+
+func (l *Local) Open(name string) (File, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(l.Path(name))
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (l *Local) Delete(name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	return os.Remove(l.Path(name))
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// failingReader отдает data и затем возвращает ошибку, как прерванная запись архива
+type failingReader struct {
+	data string
+	done bool
+}
+
+var errRead = errors.New("read failed")
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, errRead
+	}
+	r.done = true
+	return copy(p, r.data), nil
+}
+
+// dirNames возвращает имена всех файлов директории, включая временные
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestLocalPut(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+
+	if err := l.Put("app/app.tar", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Put("app/app.tar", strings.NewReader("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ReadFile(l, "app/app.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Errorf("app/app.tar = %q, want %q", data, "second")
+	}
+	if names := dirNames(t, filepath.Join(root, "app")); len(names) != 1 {
+		t.Errorf("files left in storage: %v", names)
+	}
+}
+
+func TestLocalPutFailed(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+
+	if err := WriteFile(l, "app.tar", []byte("original")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := l.Put("app.tar", &failingReader{data: "partial"})
+	if !errors.Is(err, errRead) {
+		t.Fatalf("Put error = %v, want %v", err, errRead)
+	}
+
+	// Прерванная запись не заменяет объект и не оставляет временный файл
+	data, err := ReadFile(l, "app.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "original" {
+		t.Errorf("app.tar = %q after failed Put, want %q", data, "original")
+	}
+	if names := dirNames(t, root); len(names) != 1 {
+		t.Errorf("files left in storage: %v", names)
+	}
+
+	if err := l.Put("new.tar", &failingReader{data: "partial"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if Exists(l, "new.tar") {
+		t.Error("new.tar created by failed Put")
+	}
+}
+
+func TestLocalPutMode(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+
+	// Права объекта должны совпадать с файлом, созданным os.Create при текущем umask
+	reference := filepath.Join(t.TempDir(), "reference")
+	file, err := os.Create(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	want, err := os.Stat(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(l, "app.tar", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.Stat("app.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode().Perm() != want.Mode().Perm() {
+		t.Errorf("app.tar mode %v, want %v", got.Mode().Perm(), want.Mode().Perm())
+	}
+}
+
+func TestLocalInvalidName(t *testing.T) {
+	l := NewLocal(t.TempDir())
+
+	for _, name := range []string{"", "..", "../app.tar", "/app.tar", "app/../../app.tar"} {
+		if err := l.Put(name, strings.NewReader("data")); err == nil {
+			t.Errorf("Put(%q): expected error", name)
+		}
+		if _, err := l.Stat(name); err == nil {
+			t.Errorf("Stat(%q): expected error", name)
+		}
+		if err := l.Delete(name); err == nil {
+			t.Errorf("Delete(%q): expected error", name)
+		}
+	}
+}
+
+func TestLocalList(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+
+	files, err := l.List("missing")
+	if err != nil || len(files) != 0 {
+		t.Errorf("List(missing) = %v, %v; want empty list", files, err)
+	}
+
+	for _, name := range []string{"app/a.tar", "app/b.tar", "app/nested/c.tar", "other/d.tar"} {
+		if err := WriteFile(l, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err = l.List("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a.tar,b.tar" {
+		t.Errorf("List(app) = %v, want [a.tar b.tar]", names)
+	}
+
+	if err := l.Delete("app/a.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete("app/a.tar"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Delete of missing object error = %v, want fs.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"goback/utils"
+)
+
+// Backend - хранилище архивов и их вспомогательных файлов. Объекты называются путями
+// относительно корня хранилища с / в качестве разделителя: <subdirectory>/<archive>
+type Backend interface {
+	// Put записывает поток r в объект name, заменяя существующий. Если чтение r завершилось
+	// ошибкой, объект не создается
+	Put(name string, r io.Reader) error
+	// List возвращает объекты директории dir без вложенных директорий;
+	// для несуществующей директории возвращается пустой список
+	List(dir string) ([]fs.FileInfo, error)
+	// Stat возвращает сведения об объекте; для отсутствующего объекта ошибка - fs.ErrNotExist
+	Stat(name string) (fs.FileInfo, error)
+	// Open открывает объект для последовательного и произвольного чтения
+	Open(name string) (File, error)
+	// Delete удаляет объект; отсутствующий объект - ошибка fs.ErrNotExist
+	Delete(name string) error
+}
+
+// File - открытый для чтения объект хранилища
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Closer
+	Stat() (fs.FileInfo, error)
+}
+
+// locator реализуется хранилищами, объекты которых имеют путь в файловой системе
+type locator interface {
+	Path(name string) string
+}
+
+// Location возвращает расположение объекта для вывода пользователю:
+// путь в файловой системе для локального хранилища, иначе имя объекта
+func Location(b Backend, name string) string {
+	if l, ok := b.(locator); ok {
+		return l.Path(name)
+	}
+	return name
+}
+
+// Join собирает имя объекта из частей
+func Join(elem ...string) string {
+	return strings.TrimPrefix(path.Join(elem...), "/")
+}
+
+// validName проверяет, что имя объекта не выходит за корень хранилища
+func validName(name string) error {
+	clean := path.Clean(name)
+	if name == "" || path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("invalid object name: %q", name)
+	}
+	return nil
+}
+
+// Exists проверяет наличие объекта
+func Exists(b Backend, name string) bool {
+	_, err := b.Stat(name)
+	return err == nil
+}
+
+// ReadFile читает объект целиком
+func ReadFile(b Backend, name string) ([]byte, error) {
+	file, err := b.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// WriteFile записывает объект из data
+func WriteFile(b Backend, name string, data []byte) error {
+	return b.Put(name, strings.NewReader(string(data)))
+}
+
+// Volumes возвращает объекты архива name: сам архив или, если он разбит на тома,
+// тома по порядку начиная с .001
+func Volumes(b Backend, name string) ([]string, error) {
+	_, err := b.Stat(name)
+	if err == nil {
+		return []string{name}, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var volumes []string
+	for n := 1; ; n++ {
+		volume := utils.VolumeName(name, n)
+		if _, err := b.Stat(volume); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			return nil, err
+		}
+		volumes = append(volumes, volume)
+	}
+
+	if len(volumes) == 0 {
+		return nil, err
+	}
+	return volumes, nil
+}
+
+// errAborted передается Put, если запись объекта отменена
+var errAborted = errors.New("write aborted")
+
+// Writer записывает объект хранилища потоком. Данные передаются в Put через pipe,
+// объект сохраняется при Close; Abort отменяет запись
+type Writer struct {
+	pipe *io.PipeWriter
+	done chan error
+	err  error
+	open bool
+}
+
+// Create начинает запись объекта name
+func Create(b Backend, name string) *Writer {
+	r, w := io.Pipe()
+	writer := &Writer{pipe: w, done: make(chan error, 1), open: true}
+
+	go func() {
+		err := b.Put(name, r)
+		// Put мог вернуться, не дочитав поток: разблокируем пишущего
+		r.CloseWithError(err)
+		writer.done <- err
+	}()
+
+	return writer
+}
+
+// Write передает данные в Put; если Put завершился с ошибкой, возвращается она
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.pipe.Write(p)
+}
+
+// Close завершает поток и ждет сохранения объекта; повторный вызов возвращает тот же результат
+func (w *Writer) Close() error {
+	return w.finish(nil)
+}
+
+// Abort отменяет запись объекта. После Close ничего не делает
+func (w *Writer) Abort() {
+	w.finish(errAborted)
+}
+
+func (w *Writer) finish(err error) error {
+	if !w.open {
+		return w.err
+	}
+	w.open = false
+
+	if err != nil {
+		w.pipe.CloseWithError(err)
+	} else {
+		w.pipe.Close()
+	}
+	w.err = <-w.done
+	return w.err
+}
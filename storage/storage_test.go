@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestVolumes(t *testing.T) {
+	l := NewLocal(t.TempDir())
+
+	if _, err := Volumes(l, "app/app.tar"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Volumes of missing archive error = %v, want fs.ErrNotExist", err)
+	}
+
+	if err := WriteFile(l, "app/app.tar", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := Volumes(l, "app/app.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(volumes, ",") != "app/app.tar" {
+		t.Errorf("Volumes = %v, want [app/app.tar]", volumes)
+	}
+
+	// Тома читаются по порядку до первого пропущенного номера
+	for _, name := range []string{"app/split.tar.002", "app/split.tar.001", "app/split.tar.003", "app/split.tar.005"} {
+		if err := WriteFile(l, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	volumes, err = Volumes(l, "app/split.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(volumes, ","); got != "app/split.tar.001,app/split.tar.002,app/split.tar.003" {
+		t.Errorf("Volumes = %s", got)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	l := NewLocal(t.TempDir())
+
+	w := Create(l, "app.tar")
+	if _, err := io.WriteString(w, "part one, "); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, "part two"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Повторный Close и Abort после Close ничего не меняют
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	w.Abort()
+
+	data, err := ReadFile(l, "app.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "part one, part two" {
+		t.Errorf("app.tar = %q", data)
+	}
+}
+
+func TestWriterAbort(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+
+	w := Create(l, "app.tar")
+	if _, err := io.WriteString(w, "partial"); err != nil {
+		t.Fatal(err)
+	}
+	w.Abort()
+
+	if err := w.Close(); !errors.Is(err, errAborted) {
+		t.Errorf("Close after Abort = %v, want %v", err, errAborted)
+	}
+	if Exists(l, "app.tar") {
+		t.Error("aborted object was created")
+	}
+	if names := dirNames(t, root); len(names) != 0 {
+		t.Errorf("temporary files left after Abort: %v", names)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if got := Join("", "app", "app.tar"); got != "app/app.tar" {
+		t.Errorf("Join = %q", got)
+	}
+	if got := Join("/", "app.tar"); got != "app.tar" {
+		t.Errorf("Join = %q", got)
+	}
+}
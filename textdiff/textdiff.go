@@ -0,0 +1,314 @@
+package textdiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxEdits - наибольшее число удаленных и добавленных строк, для которого строится diff.
+// Время поиска растет как произведение длины текстов на число изменений, поэтому
+// для почти полностью различающихся больших дампов diff не строится
+const MaxEdits = 10000
+
+// ErrTooManyChanges возвращается, если тексты различаются больше чем на MaxEdits строк
+var ErrTooManyChanges = errors.New("too many changed lines")
+
+// opKind - тип строки в редакционном предписании
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	// Индексы строки в старом (a) и новом (b) текстах
+	a, b int
+}
+
+// Unified возвращает разницу двух текстов в формате unified diff с context строками контекста.
+// Для одинаковых текстов возвращается пустая строка
+func Unified(nameA, nameB string, a, b []byte, context int) (string, error) {
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+
+	ops, ok := diffLines(linesA, linesB, MaxEdits)
+	if !ok {
+		return "", ErrTooManyChanges
+	}
+
+	var sb strings.Builder
+	for _, hunk := range groupHunks(ops, context) {
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		writeHunk(&sb, hunk, linesA, linesB)
+	}
+
+	return sb.String(), nil
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines строит кратчайшее редакционное предписание алгоритмом Майерса в линейной памяти:
+// текст делится пополам по середине оптимального пути, и половины сравниваются рекурсивно.
+// Сохранение всех шагов прямого алгоритма требует O(D²) памяти, чего не выдерживают
+// сильно различающиеся дампы. ok = false, если предписание длиннее maxEdits
+func diffLines(a, b []string, maxEdits int) (ops []op, ok bool) {
+	d := &differ{a: a, b: b, maxEdits: maxEdits}
+	d.compare(0, len(a), 0, len(b))
+	return d.ops, !d.exceeded
+}
+
+// differ накапливает предписание для строк a[a0:a1] и b[b0:b1] по порядку
+type differ struct {
+	a, b []string
+	ops  []op
+	// maxEdits ограничивает длину предписания; exceeded - ограничение превышено, поиск прерван
+	maxEdits int
+	edits    int
+	exceeded bool
+}
+
+func (d *differ) compare(a0, a1, b0, b1 int) {
+	if d.exceeded {
+		return
+	}
+
+	// Общие начало и конец отбрасываются заранее, так как в дампах обычно меняется небольшая часть строк
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.ops = append(d.ops, op{kind: opEqual, a: a0, b: b0})
+		a0++
+		b0++
+	}
+
+	suffix := 0
+	for a1-suffix > a0 && b1-suffix > b0 && d.a[a1-1-suffix] == d.b[b1-1-suffix] {
+		suffix++
+	}
+	a1 -= suffix
+	b1 -= suffix
+
+	switch {
+	case a0 == a1 || b0 == b1:
+		d.replace(a0, a1, b0, b1)
+	default:
+		x, y, ok := d.middle(a0, a1, b0, b1)
+		switch {
+		case ok:
+			d.compare(a0, x, b0, y)
+			d.compare(x, a1, y, b1)
+		case !d.exceeded:
+			d.replace(a0, a1, b0, b1)
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.ops = append(d.ops, op{kind: opEqual, a: a1 + i, b: b1 + i})
+	}
+}
+
+// replace удаляет строки a[a0:a1] и вставляет строки b[b0:b1]
+func (d *differ) replace(a0, a1, b0, b1 int) {
+	d.edits += (a1 - a0) + (b1 - b0)
+	if d.edits > d.maxEdits {
+		d.exceeded = true
+		return
+	}
+
+	for x := a0; x < a1; x++ {
+		d.ops = append(d.ops, op{kind: opDelete, a: x, b: b0})
+	}
+	for y := b0; y < b1; y++ {
+		d.ops = append(d.ops, op{kind: opInsert, a: a1, b: y})
+	}
+}
+
+// middle ищет точку оптимального пути, одновременно продвигаясь от начала и от конца текстов,
+// пока пути не встретятся. ok = false, если у текстов нет общих строк или предписание длиннее maxEdits
+func (d *differ) middle(a0, a1, b0, b1 int) (x, y int, ok bool) {
+	n, m := a1-a0, b1-b0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+
+	// forward[offset+k] - самая дальняя точка x на диагонали k от начала,
+	// backward[offset+k] - то же от конца; -1 - диагональ еще не достигнута
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	// При нечетной разнице длин пути встречаются на шаге прямого прохода, иначе обратного
+	odd := delta%2 != 0
+	// Диагонали, вышедшие за границы текстов, больше не просматриваются
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+
+	for step := 0; step < maxD; step++ {
+		// Пути встречаются на шаге step при длине предписания 2*step-1 или 2*step
+		if 2*step-1 > d.maxEdits {
+			d.exceeded = true
+			return 0, 0, false
+		}
+
+		for k := -step + forwardStart; k <= step-forwardEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -step || (k != step && forward[i-1] < forward[i+1]) {
+				fx = forward[i+1]
+			} else {
+				fx = forward[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && d.a[a0+fx] == d.b[b0+fy] {
+				fx++
+				fy++
+			}
+			forward[i] = fx
+
+			switch {
+			case fx > n:
+				forwardEnd += 2
+			case fy > m:
+				forwardStart += 2
+			case odd:
+				j := offset + delta - k
+				if j >= 0 && j < len(backward) && backward[j] != -1 && fx >= n-backward[j] {
+					return a0 + fx, b0 + fy, true
+				}
+			}
+		}
+
+		for k := -step + backwardStart; k <= step-backwardEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -step || (k != step && backward[i-1] < backward[i+1]) {
+				bx = backward[i+1]
+			} else {
+				bx = backward[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && d.a[a1-1-bx] == d.b[b1-1-by] {
+				bx++
+				by++
+			}
+			backward[i] = bx
+
+			switch {
+			case bx > n:
+				backwardEnd += 2
+			case by > m:
+				backwardStart += 2
+			case !odd:
+				j := offset + delta - k
+				if j >= 0 && j < len(forward) && forward[j] != -1 {
+					fx := forward[j]
+					if fx >= n-bx {
+						return a0 + fx, b0 + fx - (j - offset), true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// groupHunks разбивает предписание на фрагменты с context строками контекста вокруг изменений
+func groupHunks(ops []op, context int) [][]op {
+	var hunks [][]op
+
+	i := 0
+	for i < len(ops) {
+		for i < len(ops) && ops[i].kind == opEqual {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		start := max(i-context, 0)
+
+		// Объединяем изменения, между которыми не больше 2*context общих строк
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				break
+			}
+			end = run
+		}
+
+		stop := min(end+context, len(ops))
+		hunks = append(hunks, ops[start:stop])
+		i = stop
+	}
+
+	return hunks
+}
+
+func writeHunk(sb *strings.Builder, hunk []op, a, b []string) {
+	lenA, lenB := 0, 0
+	for _, o := range hunk {
+		if o.kind != opInsert {
+			lenA++
+		}
+		if o.kind != opDelete {
+			lenB++
+		}
+	}
+
+	// Для пустого диапазона указывается номер строки перед ним
+	startA := hunk[0].a + 1
+	if lenA == 0 {
+		startA--
+	}
+	startB := hunk[0].b + 1
+	if lenB == 0 {
+		startB--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", startA, lenA, startB, lenB)
+
+	for _, o := range hunk {
+		switch o.kind {
+		case opEqual:
+			writeLine(sb, ' ', a[o.a])
+		case opDelete:
+			writeLine(sb, '-', a[o.a])
+		case opInsert:
+			writeLine(sb, '+', b[o.b])
+		}
+	}
+}
+
+func writeLine(sb *strings.Builder, prefix byte, line string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,186 @@
+package textdiff
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{name: "identical", a: "a\nb\n", b: "a\nb\n", want: ""},
+		{name: "empty", a: "", b: "", want: ""},
+		{
+			name:    "changed line",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:       "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			context: 3,
+			want:    "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "added to empty",
+			a:    "",
+			b:    "a\nb\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removed all",
+			a:    "a\nb\n",
+			b:    "",
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "no newline at end",
+			a:    "a\nb",
+			b:    "a\nc",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n-1\n+one\n 2\n@@ -9,2 +9,2 @@\n 9\n-10\n+ten\n",
+		},
+	}
+
+	for _, tt := range tests {
+		context := tt.context
+		if context == 0 {
+			context = 1
+		}
+		got, err := Unified("old", "new", []byte(tt.a), []byte(tt.b), context)
+		if err != nil {
+			t.Errorf("%s: Unified error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Unified =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// lcsLength возвращает длину наибольшей общей подпоследовательности строк
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(cur[j], prev[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// checkOps проверяет, что предписание превращает a в b и что оно кратчайшее
+func checkOps(t *testing.T, a, b []string, ops []op) {
+	t.Helper()
+
+	var gotA, gotB []string
+	edits := 0
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			if a[o.a] != b[o.b] {
+				t.Fatalf("equal op joins different lines %q and %q", a[o.a], b[o.b])
+			}
+			gotA = append(gotA, a[o.a])
+			gotB = append(gotB, b[o.b])
+		case opDelete:
+			gotA = append(gotA, a[o.a])
+			edits++
+		case opInsert:
+			gotB = append(gotB, b[o.b])
+			edits++
+		}
+	}
+
+	if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+		t.Fatalf("ops do not reproduce the texts:\na = %q\nb = %q\nops = %v", a, b, ops)
+	}
+	if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+		t.Fatalf("%d edits, want %d:\na = %q\nb = %q", edits, want, a, b)
+	}
+}
+
+func randomLines(r *rand.Rand, n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = string(rune('a'+r.Intn(4))) + "\n"
+	}
+	return lines
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		a := randomLines(r, r.Intn(20))
+		b := randomLines(r, r.Intn(20))
+		// Кратчайшее предписание укладывается в ограничение, равное его длине
+		ops, ok := diffLines(a, b, len(a)+len(b)-2*lcsLength(a, b))
+		if !ok {
+			t.Fatalf("diff of %q and %q exceeded the edit limit", a, b)
+		}
+		checkOps(t, a, b, ops)
+	}
+}
+
+func TestDiffLinesMaxEdits(t *testing.T) {
+	a := strings.SplitAfter("a\nb\nc\nd\ne\nf\n", "\n")
+	b := strings.SplitAfter("a\nB\nc\nD\ne\nF\n", "\n")
+
+	// Шесть измененных строк: три удаления и три вставки
+	if _, ok := diffLines(a, b, 6); !ok {
+		t.Error("diff with 6 edits exceeded limit 6")
+	}
+	if _, ok := diffLines(a, b, 5); ok {
+		t.Error("diff with 6 edits fit limit 5")
+	}
+	if _, ok := diffLines(nil, b, 5); ok {
+		t.Error("insertion of 6 lines fit limit 5")
+	}
+
+	large := strings.Repeat("line\n", MaxEdits+1)
+	if _, err := Unified("old", "new", nil, []byte(large), 3); !errors.Is(err, ErrTooManyChanges) {
+		t.Errorf("Unified error = %v, want %v", err, ErrTooManyChanges)
+	}
+}
+
+func TestDiffLinesLargeMemory(t *testing.T) {
+	// Полностью разные дампы по 5000 строк: сохранение всех шагов занимало сотни мегабайт
+	const lines = 5000
+	var a, b strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&a, "INSERT INTO t VALUES (%d, 'old');\n", i)
+		fmt.Fprintf(&b, "INSERT INTO t VALUES (%d, 'new');\n", i)
+	}
+
+	linesA, linesB := splitLines([]byte(a.String())), splitLines([]byte(b.String()))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ops, ok := diffLines(linesA, linesB, 2*lines)
+	runtime.ReadMemStats(&after)
+
+	if !ok {
+		t.Fatalf("diff of %d lines exceeded the edit limit", lines)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("diff of %d lines allocated %d MiB", lines, allocated>>20)
+	}
+	if len(ops) != 2*lines {
+		t.Errorf("%d ops, want %d", len(ops), 2*lines)
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
@@ -14,33 +15,42 @@ const (
 	ColorYellow = "\033[33m"
 )
 
+// Output - поток для информационных сообщений. Подкоманды, пишущие данные в stdout,
+// перенаправляют его в stderr
+var Output io.Writer = os.Stdout
+
+// Printf выводит информационное сообщение без цвета, скрывая секреты
+func Printf(format string, args ...interface{}) {
+	fmt.Fprint(Output, Mask(fmt.Sprintf(format, args...)))
+}
+
 // PrintSuccess выводит успешное сообщение зеленым цветом
 func PrintSuccess(format string, args ...interface{}) {
-	fmt.Printf("%s%s%s\n", ColorGreen, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Fprintf(Output, "%s%s%s\n", ColorGreen, Mask(fmt.Sprintf(format, args...)), ColorReset)
 }
 
 // PrintError выводит сообщение об ошибке красным цветом
 func PrintError(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, "%s%s%s\n", ColorRed, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Fprintf(os.Stderr, "%s%s%s\n", ColorRed, Mask(fmt.Sprintf(format, args...)), ColorReset)
 }
 
 // PrintHeader выводит заголовок оранжевым цветом
 func PrintHeader(format string, args ...interface{}) {
-	fmt.Printf("%s%s%s\n", ColorOrange, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Fprintf(Output, "%s%s%s\n", ColorOrange, Mask(fmt.Sprintf(format, args...)), ColorReset)
 }
 
 // PrintSuccessf выводит успешное сообщение зеленым цветом (аналог Printf)
 func PrintSuccessf(format string, args ...interface{}) {
-	fmt.Printf("%s%s%s", ColorGreen, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Fprintf(Output, "%s%s%s", ColorGreen, Mask(fmt.Sprintf(format, args...)), ColorReset)
 }
 
 // PrintErrorf выводит сообщение об ошибке красным цветом (аналог Printf)
 func PrintErrorf(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, "%s%s%s", ColorRed, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Fprintf(os.Stderr, "%s%s%s", ColorRed, Mask(fmt.Sprintf(format, args...)), ColorReset)
 }
 
 // PrintHeaderf выводит заголовок оранжевым цветом (аналог Printf)
 func PrintHeaderf(format string, args ...interface{}) {
-	fmt.Printf("%s%s%s", ColorOrange, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Fprintf(Output, "%s%s%s", ColorOrange, Mask(fmt.Sprintf(format, args...)), ColorReset)
 }
 
@@ -21,18 +21,36 @@ func GenerateFilename(mask, name string, t time.Time) string {
 	return result
 }
 
+// ChecksumExtension - расширение файла с контрольной суммой SHA-256 архива
+const ChecksumExtension = ".sha256"
+
+// ManifestExtension - расширение файла со списком содержимого архива
+const ManifestExtension = ".manifest.json"
+
+// SignatureExtension - расширение файла с подписью контрольных сумм архива
+const SignatureExtension = ".sig"
+
+// SidecarExtensions - расширения вспомогательных файлов, которые хранятся рядом с архивом
+// и удаляются вместе с ним
+var SidecarExtensions = []string{ChecksumExtension, ManifestExtension, SignatureExtension}
+
+// IsSidecar проверяет, является ли файл вспомогательным файлом архива
+func IsSidecar(filename string) bool {
+	for _, ext := range SidecarExtensions {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// dateRe находит YYYYMMDDHHmmss в конце имени, допуская составные расширения (.tar.gz)
+var dateRe = regexp.MustCompile(`(\d{14})(?:\.[A-Za-z0-9]+)*$`)
+
 // ParseDateFromFilename извлекает дату из имени файла
 // Формат: {name}-{YYYYMMDDHHmmss}.{ext}
 func ParseDateFromFilename(filename string) (time.Time, error) {
-	// Убираем расширение
-	base := filename
-	if idx := strings.LastIndex(filename, "."); idx != -1 {
-		base = filename[:idx]
-	}
-
-	// Ищем паттерн YYYYMMDDHHmmss в конце имени
-	re := regexp.MustCompile(`(\d{14})$`)
-	matches := re.FindStringSubmatch(base)
+	matches := dateRe.FindStringSubmatch(filename)
 	if len(matches) < 2 {
 		return time.Time{}, fmt.Errorf("cannot parse date from filename: %s", filename)
 	}
@@ -62,3 +80,20 @@ func GetExtension(compression string) string {
 	}
 }
 
+// DetectCompression определяет тип сжатия по имени файла архива
+func DetectCompression(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".gz"):
+		return "gzip"
+	default:
+		return "none"
+	}
+}
+
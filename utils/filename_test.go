@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateFromFilename(t *testing.T) {
+	want := time.Date(2024, 12, 14, 15, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		filename string
+		wantErr  bool
+	}{
+		{filename: "backup-20241214153045.tar.gz"},
+		{filename: "backup-20241214153045.gz"},
+		{filename: "backup-20241214153045"},
+		{filename: "db-dump-20241214153045.sql.zst.enc"},
+		{filename: "backup-20241214153045.tar.gz.sha256"},
+		{filename: "backup.tar.gz", wantErr: true},
+		{filename: "backup-2024121415304.tar", wantErr: true},
+		{filename: "backup-20241314153045.tar", wantErr: true},
+		{filename: "backup-20241214153045-old.tar", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDateFromFilename(tt.filename)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDateFromFilename(%q) = %v, want error", tt.filename, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDateFromFilename(%q) error: %v", tt.filename, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("ParseDateFromFilename(%q) = %v, want %v", tt.filename, got, want)
+		}
+	}
+}
+
+func TestGenerateFilenameRoundTrip(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	name := GenerateFilename("%name%-%Y%m%d%H%M%S", "site", now) + ".tar.gz"
+	if name != "site-20240102030405.tar.gz" {
+		t.Fatalf("GenerateFilename = %q", name)
+	}
+
+	got, err := ParseDateFromFilename(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(now) {
+		t.Errorf("ParseDateFromFilename(%q) = %v, want %v", name, got, now)
+	}
+}
+
+func TestDetectCompression(t *testing.T) {
+	tests := map[string]string{
+		"a-20240102030405.tar.gz": "tar.gz",
+		"a-20240102030405.TAR":    "tar",
+		"a-20240102030405.zip":    "zip",
+		"a-20240102030405.gz":     "gzip",
+		"a-20240102030405.sql":    "none",
+	}
+
+	for filename, want := range tests {
+		if got := DetectCompression(filename); got != want {
+			t.Errorf("DetectCompression(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
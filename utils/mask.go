@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secretMask заменяет значения секретов в выводе
+const secretMask = "******"
+
+// minSecretLength - наименьшая длина маскируемого значения. Более короткие значения
+// не похожи на пароли и токены, а их маскирование портило бы посторонний вывод
+const minSecretLength = 6
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+	masker    *strings.Replacer
+)
+
+// AddSecret регистрирует значение, которое нужно скрывать во всем выводе goback.
+// Значения короче minSecretLength не регистрируются
+func AddSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	for _, secret := range secrets {
+		if secret == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+
+	// Длинные секреты заменяются первыми, чтобы секрет, содержащий другой, скрывался целиком
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	pairs := make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, secretMask)
+	}
+	masker = strings.NewReplacer(pairs...)
+}
+
+// Mask скрывает зарегистрированные секреты в строке
+func Mask(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	if masker == nil {
+		return s
+	}
+	return masker.Replace(s)
+}
+
+// MaskWriter возвращает поток, скрывающий секреты в данных, записанных в w.
+// Используется для вывода запущенных команд; без секретов w возвращается как есть,
+// чтобы команда писала в него напрямую
+func MaskWriter(w io.Writer) io.Writer {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	if masker == nil {
+		return w
+	}
+	return maskWriter{w: w}
+}
+
+// maskWriter скрывает секреты в каждой записи. Команды пишут вывод строками,
+// поэтому секрет, разрезанный между двумя записями, не рассматривается
+type maskWriter struct {
+	w io.Writer
+}
+
+func (m maskWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(m.w, Mask(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	AddSecret("db")
+	AddSecret("hunter2-pass")
+	AddSecret("hunter2-pass-long")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "dump of db done", want: "dump of db done"},
+		{in: "-phunter2-pass", want: "-p******"},
+		// Секрет, содержащий другой, скрывается целиком
+		{in: "-phunter2-pass-long", want: "-p******"},
+	}
+
+	for _, tt := range tests {
+		if got := Mask(tt.in); got != tt.want {
+			t.Errorf("Mask(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := MaskWriter(&buf)
+	if n, err := w.Write([]byte("error: hunter2-pass\n")); err != nil || n != 20 {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if buf.String() != "error: ******\n" {
+		t.Errorf("MaskWriter wrote %q", buf.String())
+	}
+}
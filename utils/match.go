@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchPath проверяет путь элемента архива на соответствие glob-шаблону.
+// Помимо синтаксиса path.Match поддерживается "**" - любое количество сегментов пути.
+// Шаблон, совпавший с директорией, охватывает все ее содержимое
+func MatchPath(pattern, name string) bool {
+	pattern = strings.Trim(path.Clean("/"+pattern), "/")
+	name = strings.Trim(path.Clean("/"+name), "/")
+
+	patternParts := strings.Split(pattern, "/")
+	nameParts := strings.Split(name, "/")
+
+	// Проверяем сам путь и все его родительские директории
+	for i := len(nameParts); i > 0; i-- {
+		if matchSegments(patternParts, nameParts[:i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		// "**" поглощает от нуля до всех оставшихся сегментов
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+
+	matched, err := path.Match(pattern[0], name[0])
+	if err != nil || !matched {
+		return false
+	}
+
+	return matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,38 @@
+package utils
+
+import "testing"
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"config.php", "config.php", true},
+		{"config.php", "sub/config.php", false},
+		{"*.php", "index.php", true},
+		{"*.php", "sub/index.php", false},
+		{"wp-content", "wp-content/uploads/a.jpg", true},
+		{"wp-content/", "wp-content/uploads/a.jpg", true},
+		{"/wp-content", "wp-content/uploads/a.jpg", true},
+		{"wp-content", "wp-content-old/a.jpg", false},
+		{"wp-content/uploads/2024/**", "wp-content/uploads/2024/01/a.jpg", true},
+		{"wp-content/uploads/2024/**", "wp-content/uploads/2023/01/a.jpg", false},
+		{"**/*.log", "app.log", true},
+		{"**/*.log", "var/log/app.log", true},
+		{"**/*.log", "var/log/app.txt", false},
+		{"a/**/z", "a/z", true},
+		{"a/**/z", "a/b/c/z", true},
+		{"a/**/z", "a/b/c/y", false},
+		{"a/*/c", "a/b/c/d", true},
+		{"a/*/c", "a/b/x/c", false},
+		{"./a/../b", "b/file", true},
+		{"[", "[", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatSize форматирует размер в байтах в человекочитаемый вид (KiB, MiB, ...)
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// sizeUnits - множители единиц размера; K, M, G без суффикса считаются двоичными
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KIB": 1 << 10,
+	"KB":  1000,
+	"M":   1 << 20,
+	"MIB": 1 << 20,
+	"MB":  1000 * 1000,
+	"G":   1 << 30,
+	"GIB": 1 << 30,
+	"GB":  1000 * 1000 * 1000,
+	"T":   1 << 40,
+	"TIB": 1 << 40,
+	"TB":  1000 * 1000 * 1000 * 1000,
+}
+
+// ParseSize разбирает размер вида "512", "10MiB", "2GiB" или "4GB" в байты
+func ParseSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+
+	i := 0
+	for i < len(value) && (value[i] >= '0' && value[i] <= '9' || value[i] == '.') {
+		i++
+	}
+
+	number, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+
+	multiplier, exists := sizeUnits[strings.ToUpper(strings.TrimSpace(value[i:]))]
+	if !exists {
+		return 0, fmt.Errorf("invalid size unit: %q", value)
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
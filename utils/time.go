@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeLayouts - форматы времени без часового пояса, принимаемые в параметрах командной строки
+var timeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"20060102150405",
+}
+
+// ParseTime разбирает время из параметра командной строки.
+// Время без явного смещения (RFC 3339) интерпретируется в часовом поясе loc
+func ParseTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q: expected YYYY-MM-DD[THH:MM[:SS]], YYYYMMDDHHMMSS or RFC 3339", value)
+}
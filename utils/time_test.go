@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2026-10-01T12:00:05", want: time.Date(2026, 10, 1, 12, 0, 5, 0, moscow)},
+		{value: "2026-10-01T12:00", want: time.Date(2026, 10, 1, 12, 0, 0, 0, moscow)},
+		{value: "2026-10-01 12:00:05", want: time.Date(2026, 10, 1, 12, 0, 5, 0, moscow)},
+		{value: "2026-10-01 12:00", want: time.Date(2026, 10, 1, 12, 0, 0, 0, moscow)},
+		{value: "2026-10-01", want: time.Date(2026, 10, 1, 0, 0, 0, 0, moscow)},
+		{value: "20261001120005", want: time.Date(2026, 10, 1, 12, 0, 5, 0, moscow)},
+		// Явное смещение имеет приоритет над часовым поясом
+		{value: "2026-10-01T12:00:00Z", want: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{value: "2026-10-01T12:00:00+05:00", want: time.Date(2026, 10, 1, 7, 0, 0, 0, time.UTC)},
+		{value: "yesterday", wantErr: true},
+		{value: "2026-13-01", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTime(tt.value, moscow)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTime(%q) = %v, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTime(%q) error: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
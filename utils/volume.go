@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// volumeRe находит номер тома (.001, .002, ...) в конце имени файла
+var volumeRe = regexp.MustCompile(`^(.+)\.(\d{3,})$`)
+
+// VolumeName возвращает имя тома с номером n (начиная с 1) для архива path
+func VolumeName(path string, n int) string {
+	return fmt.Sprintf("%s.%03d", path, n)
+}
+
+// SplitVolume разбирает имя тома на имя архива и номер тома
+func SplitVolume(filename string) (string, int, bool) {
+	matches := volumeRe.FindStringSubmatch(filename)
+	if matches == nil {
+		return "", 0, false
+	}
+
+	n, err := strconv.Atoi(matches[2])
+	if err != nil || n == 0 {
+		return "", 0, false
+	}
+	return matches[1], n, true
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "testing"
+
+func TestSplitVolume(t *testing.T) {
+	tests := []struct {
+		filename string
+		base     string
+		n        int
+		ok       bool
+	}{
+		{filename: "app-20240102030405.tar.001", base: "app-20240102030405.tar", n: 1, ok: true},
+		{filename: "app.tar.gz.042", base: "app.tar.gz", n: 42, ok: true},
+		{filename: "app.tar.1000", base: "app.tar", n: 1000, ok: true},
+		{filename: "app.tar.000"},
+		{filename: "app.tar.01"},
+		{filename: "app.tar"},
+		{filename: ".001"},
+	}
+
+	for _, tt := range tests {
+		base, n, ok := SplitVolume(tt.filename)
+		if base != tt.base || n != tt.n || ok != tt.ok {
+			t.Errorf("SplitVolume(%q) = %q, %d, %v; want %q, %d, %v", tt.filename, base, n, ok, tt.base, tt.n, tt.ok)
+		}
+	}
+
+	if name := VolumeName("app.tar", 7); name != "app.tar.007" {
+		t.Errorf("VolumeName = %q", name)
+	}
+}